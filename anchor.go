@@ -0,0 +1,129 @@
+package rj
+
+// An anchor names a value so that it can be reused later in the document by an alias:
+//
+//	origins: &origins ["a.com", "b.com"]
+//	admin: {origins: *origins}
+//
+// The anchor must be defined before its aliases. An alias of a node or node list is a copy of it,
+// or the anchored value itself with ParseOptions.ShareAliases. A name which is anchored again refers
+// to the latest value from then on.
+
+// scanAnchor scans an anchored value, like &name value.
+func (s *scanner) scanAnchor() (val interface{}, err error) {
+	start := s.offset
+	s.offset++ // skip '&'
+	name := s.scanAnchorName()
+	if name == "" || s.offset >= s.len || !isSpace(s.data[s.offset]) {
+		return nil, s.errorAt(ErrInvalidAnchor, start)
+	}
+
+	s.skipSpace()
+	if val, err = s.scanValue(); err != nil {
+		return
+	}
+	if s.anchors == nil {
+		s.anchors = make(map[string]interface{})
+	}
+	s.anchors[name] = val
+	return
+}
+
+// scanAlias scans an alias, like *name, and returns the anchored value.
+func (s *scanner) scanAlias() (val interface{}, err error) {
+	start := s.offset
+	s.offset++ // skip '*'
+	name := s.scanAnchorName()
+	if name == "" || !s.isValueEnd() {
+		return nil, s.errorAt(ErrInvalidAnchor, start)
+	}
+
+	val, ok := s.anchors[name]
+	if !ok {
+		return nil, s.errorAt(ErrUnknownAnchor, start)
+	}
+
+	if max := s.opts.MaxDocumentSize; max > 0 {
+		// an alias expands the document by its values, shared or not, so they are counted
+		// up to the limit, which also bounds the time spent counting aliases of aliases
+		s.aliased += countValues(val, max-s.aliased)
+		if s.aliased > max {
+			return nil, s.errorAt(ErrDocumentTooLarge, start)
+		}
+	}
+	if s.opts.ShareAliases {
+		return val, nil
+	}
+
+	if s.opts.MaxKeys > 0 {
+		// the keys of a copy are counted, so that aliases of aliases cannot make the document grow without limit
+		s.keys += countKeys(val)
+		if s.keys > s.opts.MaxKeys {
+			return nil, s.errorAt(ErrTooManyKeys, start)
+		}
+	}
+	return copyValue(val), nil
+}
+
+func (s *scanner) scanAnchorName() string {
+	start := s.offset
+	s.skipUntil(func(c byte) bool { return !isAnchorChar(c) })
+	return string(s.data[start:s.offset])
+}
+
+// isValueEnd reports whether a value ends at the current offset.
+func (s *scanner) isValueEnd() bool {
+	if s.offset >= s.len {
+		return true
+	}
+	c := s.data[s.offset]
+	return isSpace(c) || isLineEnd(c) || c == ',' || c == ']' || c == '}' || s.isComment()
+}
+
+func isAnchorChar(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') || c == '_' || c == '-'
+}
+
+// countKeys counts the keys of the nodes in a value.
+func countKeys(v interface{}) int {
+	count := 0
+	switch vt := v.(type) {
+	case *Node:
+		count += len(vt.keys)
+		for _, item := range vt.dict {
+			count += countKeys(item)
+		}
+	case []*Node:
+		for _, n := range vt {
+			count += countKeys(n)
+		}
+	case []interface{}:
+		for _, item := range vt {
+			count += countKeys(item)
+		}
+	}
+	return count
+}
+
+// countValues counts a value and the values in it: the values of the keys of nodes,
+// and the items of arrays and node lists. It stops counting once the count is over limit.
+func countValues(v interface{}, limit int) int {
+	count := 1
+	switch vt := v.(type) {
+	case *Node:
+		for _, k := range vt.keys {
+			if count > limit {
+				break
+			}
+			count += countValues(vt.dict[k], limit-count)
+		}
+	default:
+		for _, item := range itemsOf(v) {
+			if count > limit {
+				break
+			}
+			count += countValues(item, limit-count)
+		}
+	}
+	return count
+}
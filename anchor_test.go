@@ -0,0 +1,132 @@
+package rj
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestScanAnchors(t *testing.T) {
+	in := `origins: &origins ["a.com", "b.com"]
+limits: &limits {rate: 10
+	burst: 20}
+admin: {origins: *origins
+	limits: *limits}
+hosts: [&main "main.local", *main]
+
+[Api]
+origins: *origins
+limits: *limits
+`
+	node, err := ParseString(in)
+	if err != nil {
+		t.Fatal("Parse anchors failed, expected no error, got:", err)
+	}
+
+	if !arrayEquals(node.GetStringArray("Api.origins"), []string{"a.com", "b.com"}) ||
+		!arrayEquals(node.GetStringArray("admin.origins"), []string{"a.com", "b.com"}) ||
+		!arrayEquals(node.GetStringArray("hosts"), []string{"main.local", "main.local"}) {
+		t.Error("Parse anchors failed, got:", node.dict)
+	}
+	if node.GetInt("Api.limits.burst") != 20 || node.GetInt("admin.limits.rate") != 10 {
+		t.Error("Parse anchors failed, expected aliased nodes, got:", node.dict)
+	}
+
+	limits, _ := node.GetNode("limits")
+	api, _ := node.GetNode("Api.limits")
+	if limits == api {
+		t.Error("Parse anchors failed, expected an alias to be a copy")
+	}
+
+	node, _ = ParseWithOptions([]byte(in), ParseOptions{ShareAliases: true})
+	limits, _ = node.GetNode("limits")
+	api, _ = node.GetNode("Api.limits")
+	if limits != api {
+		t.Error("Parse anchors with shared aliases failed, expected an alias to be the anchored node")
+	}
+}
+
+func TestScanAnchorErrors(t *testing.T) {
+	type testCase struct {
+		in   string
+		code ErrorCode
+	}
+
+	cases := []testCase{
+		{"a: *b\n", ErrUnknownAnchor},
+		{"a: *b\nb: &b 1\n", ErrUnknownAnchor},
+		{"a: & 1\n", ErrInvalidAnchor},
+		{"a: &b[1]\n", ErrInvalidAnchor},
+		{"a: &b 1\nc: *b.x\n", ErrInvalidAnchor},
+		{"a: &a {b: 1\n\tc: 2}\nd: [*a, *a, *a]\n", ErrTooManyKeys},
+	}
+
+	for _, c := range cases {
+		_, err := ParseWithOptions([]byte(c.in), ParseOptions{MaxKeys: 5})
+		if !errors.Is(err, c.code) {
+			t.Error("Parse anchors failed, input:", c.in, ", expected:", c.code, ", got:", err)
+		}
+	}
+}
+
+func TestScanAnchorExpansion(t *testing.T) {
+	// each array holds eight aliases of the previous one, 8^9 numbers once expanded
+	var b strings.Builder
+	b.WriteString("l0: &l0 [1, 2, 3, 4, 5, 6, 7, 8]\n")
+	for i := 1; i <= 9; i++ {
+		prev := "*l" + strconv.Itoa(i-1)
+		b.WriteString("l" + strconv.Itoa(i) + ": &l" + strconv.Itoa(i) + " [" + strings.Repeat(prev+", ", 7) + prev + "]\n")
+	}
+	in := []byte(b.String())
+
+	for _, share := range []bool{false, true} {
+		node, err := ParseWithOptions(in, ParseOptions{MaxDocumentSize: 1000, ShareAliases: share})
+		var rjErr *RJError
+		if !errors.As(err, &rjErr) || rjErr.Errors[0].Code != ErrDocumentTooLarge || rjErr.Errors[0].Line != 4 {
+			t.Error("Parse anchors failed, expected error (document too large) at line 4, got:", err)
+		}
+		if node.Has("l3") || len(node.dict["l2"].([]interface{})) != 8 {
+			t.Error("Parse anchors failed, expected the aliases over the limit to be dropped, got:", node.Keys())
+		}
+	}
+}
+
+func TestEncodeAnchors(t *testing.T) {
+	limits := NewNode()
+	limits.set("rate", 10)
+	list := []*Node{limits, NewNode()}
+	list[1].set("x", 1)
+
+	root := NewNode()
+	root.set("name", "api")
+	root.set("limits", limits)
+	root.set("items", list)
+	root.set("again", limits)
+
+	expected := `name: "api"
+limits: &a1 {
+	rate: 10
+}
+items: [*a1,{
+	x: 1
+}]
+again: *a1
+`
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	enc.SetAnchors(true)
+	if err := enc.Encode(root); err != nil || buf.String() != expected {
+		t.Error("Encode anchors failed, expected:", expected, ", got:", buf.String(), err)
+	}
+
+	node, err := ParseString(buf.String())
+	if err != nil || node.GetInt("again.rate") != 10 {
+		t.Error("Encode anchors failed, expected the output to be parsed again, got:", err)
+	}
+
+	if bts := Marshal(root); bytes.Contains(bts, []byte("&a1")) {
+		t.Error("Marshal failed, expected no anchors by default, got:", string(bts))
+	}
+}
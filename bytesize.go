@@ -0,0 +1,90 @@
+package rj
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+)
+
+// ByteSize is a number of bytes, written with a unit like 512KiB, 10MB or 2GiB.
+type ByteSize int64
+
+// Byte size units. KB, MB... are powers of 1000, KiB, MiB... are powers of 1024.
+const (
+	B   ByteSize = 1
+	KB  ByteSize = 1000 * B
+	MB  ByteSize = 1000 * KB
+	GB  ByteSize = 1000 * MB
+	TB  ByteSize = 1000 * GB
+	PB  ByteSize = 1000 * TB
+	KiB ByteSize = 1 << 10
+	MiB ByteSize = 1 << 20
+	GiB ByteSize = 1 << 30
+	TiB ByteSize = 1 << 40
+	PiB ByteSize = 1 << 50
+)
+
+var (
+	byteSizeUnits = map[string]ByteSize{
+		"B": B, "KB": KB, "MB": MB, "GB": GB, "TB": TB, "PB": PB,
+		"KiB": KiB, "MiB": MiB, "GiB": GiB, "TiB": TiB, "PiB": PiB,
+	}
+	// units from the largest, to format a size
+	byteSizeOrder = []string{"PiB", "PB", "TiB", "TB", "GiB", "GB", "MiB", "MB", "KiB", "KB"}
+
+	// any spelling of a unit, so that ambiguous ones like K, Mb or gib are reported.
+	// A size may be negative, like the difference of two sizes.
+	byteSizeReg = regexp.MustCompile(`^[+-]?\d+(\.\d+)?([KMGTPkmgtp]([Ii]?[Bb])?|[Bb])$`)
+)
+
+// String formats the size with the largest unit that divides it.
+func (b ByteSize) String() string {
+	for _, u := range byteSizeOrder {
+		m := byteSizeUnits[u]
+		if b != 0 && b%m == 0 {
+			return strconv.FormatInt(int64(b/m), 10) + u
+		}
+	}
+	return strconv.FormatInt(int64(b), 10) + "B"
+}
+
+// isByteSize reports whether raw has the form of a byte size, a number followed by a unit.
+func isByteSize(raw string) bool {
+	return byteSizeReg.MatchString(raw)
+}
+
+// decodeByteSize decodes a byte size. It also returns the index of the unit in raw,
+// where an unknown or ambiguous unit, like K, M or Mb, is reported.
+func decodeByteSize(raw string) (val ByteSize, unitAt int, err error) {
+	unitAt = len(raw)
+	for unitAt > 0 && (raw[unitAt-1] < '0' || raw[unitAt-1] > '9') {
+		unitAt--
+	}
+
+	m, ok := byteSizeUnits[raw[unitAt:]]
+	if !ok {
+		return 0, unitAt, newError(ErrInvalidByteSize)
+	}
+
+	num := raw[:unitAt]
+	if n, e := strconv.ParseInt(num, 10, 64); e == nil {
+		if n > math.MaxInt64/int64(m) || n < math.MinInt64/int64(m) {
+			return 0, 0, newError(ErrNumberOutOfRange)
+		}
+		return ByteSize(n) * m, 0, nil
+	}
+
+	f, e := strconv.ParseFloat(num, 64)
+	if e != nil {
+		return 0, 0, newError(ErrNumberOutOfRange)
+	}
+	f *= float64(m)
+	if f >= math.MaxInt64 || f < math.MinInt64 {
+		return 0, 0, newError(ErrNumberOutOfRange)
+	}
+	if f != math.Trunc(f) {
+		// a fraction of a byte
+		return 0, 0, newError(ErrInvalidByteSize)
+	}
+	return ByteSize(f), 0, nil
+}
@@ -0,0 +1,109 @@
+package rj
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestScanByteSize(t *testing.T) {
+	cases := []testCase{
+		{input: `512KiB`, expected: 512 * KiB},
+		{input: `10MB`, expected: 10 * MB},
+		{input: `2GiB`, expected: 2 * GiB},
+		{input: `1.5KiB`, expected: ByteSize(1536)},
+		{input: `100B`, expected: ByteSize(100)},
+		{input: `-5B`, expected: ByteSize(-5)},
+		{input: `-1.5KiB`, expected: ByteSize(-1536)},
+		{input: `-9000000PiB`, err: true},
+		{input: `10K`, err: true},
+		{input: `10Mb`, err: true},
+		{input: `10gib`, err: true},
+		{input: `1.5B`, err: true},
+		{input: `9000000PiB`, err: true},
+	}
+
+	for _, tc := range cases {
+		sc := newScanner([]byte(tc.input))
+		val, err := sc.scanScalar()
+		if tc.err {
+			if err == nil {
+				t.Error("ScanByteSize failed, input:", tc.input, ", expected error")
+			}
+		} else if err != nil || val != tc.expected {
+			t.Error("ScanByteSize failed, input:", tc.input, ", expected:", tc.expected, ", got:", val, err)
+		}
+	}
+}
+
+func TestByteSizeError(t *testing.T) {
+	_, err := ParseString("size: 1Kb\n")
+	var rjErr *RJError
+	if !errors.As(err, &rjErr) || !errors.Is(err, ErrInvalidByteSize) {
+		t.Fatal("Parse ambiguous byte size failed, expected error (invalid byte size), got:", err)
+	}
+
+	expected := Position{Offset: 7, Line: 1, Column: 8}
+	if pe := rjErr.Errors[0]; pe.Position != expected || pe.Name != "size" {
+		t.Error("Parse ambiguous byte size failed, expected error at:", expected, ", got:", pe)
+	}
+}
+
+func TestByteSize_String(t *testing.T) {
+	cases := map[ByteSize]string{
+		0:           "0B",
+		100:         "100B",
+		1024:        "1KiB",
+		3000:        "3KB",
+		1536:        "1536B",
+		2 * GiB:     "2GiB",
+		5 * PB:      "5PB",
+		1025 * MiB:  "1025MiB",
+		1000 * 1000: "1MB",
+	}
+
+	for size, expected := range cases {
+		if s := size.String(); s != expected {
+			t.Error("ByteSize.String failed, expected:", expected, ", got:", s)
+		}
+	}
+}
+
+func TestDecodeByteSize(t *testing.T) {
+	var limits struct {
+		Memory  ByteSize
+		Buffer  int
+		Chunk   uint16
+		Maximum int8
+	}
+	err := Unmarshal([]byte("Memory: 2GiB\nBuffer: 512KiB\nChunk: 4KB\n"), &limits)
+	if err != nil || limits.Memory != 2*GiB || limits.Buffer != 512*1024 || limits.Chunk != 4000 {
+		t.Error("Decode byte sizes failed, got:", limits, err)
+	}
+
+	if err = Unmarshal([]byte("Maximum: 1KB"), &limits); err != errTypeMismatch {
+		t.Error("Decode overflowing byte size failed, expected error (type mismatch), got:", err)
+	}
+
+	bts, err := MarshalE(limits)
+	if err != nil || string(bts) != "Memory: 2GiB\nBuffer: 524288\nChunk: 4000\nMaximum: 0\n" {
+		t.Error("Encode byte sizes failed, got:", string(bts), err)
+	}
+
+	for _, size := range []ByteSize{-5, -2 * GiB, math.MinInt64} {
+		in := struct{ Size ByteSize }{size}
+		out := struct{ Size ByteSize }{}
+		bts, err := MarshalE(in)
+		if err == nil {
+			err = Unmarshal(bts, &out)
+		}
+		if err != nil || out.Size != size {
+			t.Errorf("Encode negative byte size failed, expected: %v, got: %v from %q, %v", size, out.Size, bts, err)
+		}
+	}
+
+	node, _ := ParseString("limit: 10MB\nplain: 42\nname: \"a\"\n")
+	if node.GetBytesSize("limit") != 10*MB || node.GetBytesSize("plain") != 42 || node.GetBytesSizeOr("name", KB) != KB {
+		t.Error("GetBytesSize failed, got:", node.GetBytesSize("limit"), node.GetBytesSize("plain"))
+	}
+}
@@ -0,0 +1,431 @@
+package rj
+
+import (
+	"bytes"
+	"io"
+	"strings"
+)
+
+// SyntaxKind is the kind of a SyntaxNode.
+type SyntaxKind int
+
+const (
+	SyntaxDocument  SyntaxKind = iota + 1 // the whole document
+	SyntaxSection                         // a section, from its header to the line that ends it
+	SyntaxListItem                        // a node of a node list, from its '-'
+	SyntaxPair                            // a key and its value
+	SyntaxArray                           // an array, from '[' to ']'
+	SyntaxObject                          // an object, from '{' to '}'
+	SyntaxToken                           // a single token
+	SyntaxTrivia                          // spaces, line ends and delimiters between tokens
+	SyntaxDirective                       // a directive and its argument, like @include "common.rj"
+)
+
+// SyntaxNode is a node of the concrete syntax tree of a Document.
+// The leaves of the tree are tokens and trivia, which together cover every byte of the source.
+type SyntaxNode struct {
+	Kind     SyntaxKind
+	Start    int    // byte offset of the node in the source
+	End      int    // byte offset right after the node
+	Token    *Token // the token of a SyntaxToken
+	Children []*SyntaxNode
+	doc      *Document
+}
+
+// Text returns the source text of the node.
+func (n *SyntaxNode) Text() string {
+	return string(n.doc.src[n.Start:n.End])
+}
+
+// Name returns the name of a section, or the key of a pair.
+func (n *SyntaxNode) Name() string {
+	if (n.Kind == SyntaxSection || n.Kind == SyntaxPair) && len(n.Children) > 0 {
+		if tok := n.Children[0].Token; tok != nil {
+			name, _ := tok.Value.(string)
+			return name
+		}
+	}
+	return ""
+}
+
+// Base returns the base section of a section which inherits from it, like base in [prod : base].
+func (n *SyntaxNode) Base() string {
+	if n.Kind == SyntaxSection && len(n.Children) > 0 {
+		if tok := n.Children[0].Token; tok != nil {
+			return tok.Base
+		}
+	}
+	return ""
+}
+
+// Value returns the value of a pair, which is a token, an array or an object.
+func (n *SyntaxNode) Value() *SyntaxNode {
+	if n.Kind != SyntaxPair {
+		return nil
+	}
+	for _, c := range n.Children[1:] {
+		if c.Kind != SyntaxTrivia {
+			return c
+		}
+	}
+	return nil
+}
+
+// pair finds the last pair with the given key among the children.
+func (n *SyntaxNode) pair(key string) *SyntaxNode {
+	var found *SyntaxNode
+	for _, c := range n.Children {
+		if c.Kind == SyntaxPair && c.Name() == key {
+			found = c
+		}
+	}
+	return found
+}
+
+// section finds the last section with the given name among the children.
+func (n *SyntaxNode) section(name string) *SyntaxNode {
+	var found *SyntaxNode
+	for _, c := range n.Children {
+		if c.Kind == SyntaxSection && c.Name() == name {
+			found = c
+		}
+	}
+	return found
+}
+
+// isList reports whether a section is a node list.
+func (n *SyntaxNode) isList() bool {
+	for _, c := range n.Children {
+		if c.Kind == SyntaxListItem {
+			return true
+		}
+	}
+	return false
+}
+
+func (n *SyntaxNode) add(child *SyntaxNode) {
+	n.Children = append(n.Children, child)
+}
+
+// Document is a lossless concrete syntax tree of an RJ document.
+// It keeps every byte of the source, including comments, blank lines, key order and quoting style,
+// so it can be edited and written back with only the edited parts changed.
+type Document struct {
+	src  []byte
+	root *SyntaxNode
+}
+
+// ParseDocument parses src into a Document.
+func ParseDocument(src []byte) (*Document, error) {
+	d := &Document{}
+	if err := d.build(src); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// Root returns the root of the syntax tree.
+func (d *Document) Root() *SyntaxNode {
+	return d.root
+}
+
+// Bytes returns the source of the document.
+func (d *Document) Bytes() []byte {
+	return append([]byte{}, d.src...)
+}
+
+func (d *Document) String() string {
+	return string(d.src)
+}
+
+// Node parses the document into a node.
+func (d *Document) Node() (*Node, error) {
+	return Parse(d.src)
+}
+
+// Set sets the value of the pair at the dot separated path.
+// Only the text of the value is replaced. If the pair does not exist,
+// it is added after the last pair of its section or object.
+func (d *Document) Set(path string, v interface{}) error {
+	text, err := marshalValue(v)
+	if err != nil {
+		return err
+	}
+
+	pair, parent, key, err := d.find(path)
+	if err != nil {
+		return err
+	}
+
+	if pair != nil {
+		val := pair.Value()
+		return d.replace(val.Start, val.End, text)
+	}
+
+	at, prefix, suffix := d.insertPoint(parent)
+	return d.replace(at, at, []byte(prefix+key+": "+string(text)+suffix))
+}
+
+// Delete removes the pair or section at the dot separated path.
+// A pair which is alone on its line is removed with its line.
+func (d *Document) Delete(path string) error {
+	pair, parent, key, err := d.find(path)
+	if err != nil {
+		return err
+	}
+
+	if pair == nil {
+		if parent == d.root {
+			if s := d.root.section(key); s != nil {
+				return d.replace(s.Start, s.End, nil)
+			}
+		}
+		return errValueNotFound
+	}
+
+	start, end := pair.Start, pair.End
+	lineStart := bytes.LastIndexByte(d.src[:start], '\n') + 1
+	if len(bytes.TrimSpace(d.src[lineStart:start])) > 0 {
+		return d.replace(start, end, nil)
+	}
+
+	// remove the whole line, with its trailing comment
+	for end < len(d.src) && !isLineEnd(d.src[end]) {
+		if !isSpace(d.src[end]) && !isComment(d.src[end:]) {
+			return d.replace(start, pair.End, nil)
+		}
+		if isComment(d.src[end:]) {
+			for end < len(d.src) && !isLineEnd(d.src[end]) {
+				end++
+			}
+			break
+		}
+		end++
+	}
+	if end < len(d.src) && d.src[end] == '\r' {
+		end++
+	}
+	if end < len(d.src) && d.src[end] == '\n' {
+		end++
+	}
+	return d.replace(lineStart, end, nil)
+}
+
+// find locates the pair at path. If there is no such pair,
+// it returns the section, object or document the pair belongs to.
+// A section with a dotted name, like [server.tls], is found by the path of its node.
+func (d *Document) find(path string) (pair, parent *SyntaxNode, key string, err error) {
+	names := strings.Split(path, ".")
+	parent = d.root
+	for i := 0; i < len(names); i++ {
+		name := names[i]
+		if name == "" {
+			return nil, nil, "", errNoName
+		}
+
+		pair = parent.pair(name)
+		if i == len(names)-1 {
+			return pair, parent, name, nil
+		}
+
+		var section *SyntaxNode
+		last := i // the last name of the section
+		if parent == d.root {
+			// the longest dotted section name that is a prefix of the rest of the path
+			for last = len(names) - 2; last >= i; last-- {
+				if section = d.root.section(strings.Join(names[i:last+1], ".")); section != nil {
+					break
+				}
+			}
+		}
+
+		switch {
+		case section != nil && (pair == nil || section.Start > pair.Start):
+			if section.isList() {
+				return nil, nil, "", errTypeMismatch
+			}
+			parent = section
+			i = last
+		case pair != nil:
+			parent = pair.Value()
+			if parent.Kind != SyntaxObject {
+				return nil, nil, "", errTypeMismatch
+			}
+		default:
+			return nil, nil, "", errValueNotFound
+		}
+	}
+	return
+}
+
+// insertPoint returns where a new pair of parent is inserted, and the text that goes around it.
+func (d *Document) insertPoint(parent *SyntaxNode) (at int, prefix, suffix string) {
+	var last *SyntaxNode
+	for _, c := range parent.Children {
+		if c.Kind == SyntaxPair {
+			last = c
+		}
+	}
+
+	if last == nil {
+		switch parent.Kind {
+		case SyntaxObject:
+			// before '}'
+			return parent.End - 1, "\n", ""
+		case SyntaxSection:
+			header := parent.Children[0]
+			return lineEnd(d.src, header.End), "\n", ""
+		default:
+			// before the first section
+			for _, c := range parent.Children {
+				if c.Kind == SyntaxSection {
+					return c.Start, "", "\n"
+				}
+			}
+			if len(d.src) == 0 || d.src[len(d.src)-1] == '\n' {
+				return len(d.src), "", "\n"
+			}
+			return len(d.src), "\n", "\n"
+		}
+	}
+
+	lineStart := bytes.LastIndexByte(d.src[:last.Start], '\n') + 1
+	indent := d.src[lineStart:last.Start]
+	if len(bytes.TrimSpace(indent)) > 0 {
+		indent = nil
+	}
+	if parent.Kind == SyntaxObject {
+		at = last.End
+	} else {
+		at = lineEnd(d.src, last.End)
+	}
+
+	return at, "\n" + string(indent), ""
+}
+
+// lineEnd returns the offset of the end of the line containing offset.
+func lineEnd(src []byte, offset int) int {
+	for offset < len(src) && !isLineEnd(src[offset]) {
+		offset++
+	}
+	return offset
+}
+
+// replace replaces src[start:end] with text and rebuilds the tree.
+// The document is not changed if the result is invalid.
+func (d *Document) replace(start, end int, text []byte) error {
+	src := make([]byte, 0, len(d.src)-(end-start)+len(text))
+	src = append(src, d.src[:start]...)
+	src = append(src, text...)
+	src = append(src, d.src[end:]...)
+
+	old := *d
+	if err := d.build(src); err != nil {
+		*d = old
+		return err
+	}
+	return nil
+}
+
+// build builds the syntax tree of src.
+func (d *Document) build(src []byte) error {
+	d.src = src
+	d.root = &SyntaxNode{Kind: SyntaxDocument, doc: d}
+
+	stack := []*SyntaxNode{d.root}
+	pos := 0
+
+	top := func() *SyntaxNode {
+		return stack[len(stack)-1]
+	}
+	// gap adds the trivia before offset to the innermost node
+	gap := func(offset int) {
+		if offset > pos {
+			top().add(&SyntaxNode{Kind: SyntaxTrivia, Start: pos, End: offset, doc: d})
+			pos = offset
+		}
+	}
+	push := func(kind SyntaxKind, start int) {
+		gap(start)
+		n := &SyntaxNode{Kind: kind, Start: start, doc: d}
+		top().add(n)
+		stack = append(stack, n)
+	}
+	pop := func() {
+		top().End = pos
+		stack = stack[:len(stack)-1]
+	}
+	leaf := func(tok Token) {
+		gap(tok.Pos.Offset)
+		top().add(&SyntaxNode{Kind: SyntaxToken, Start: tok.Pos.Offset, End: tok.End(), Token: &tok, doc: d})
+		pos = tok.End()
+	}
+	// valueDone closes the pair or directive whose value is complete
+	valueDone := func() {
+		if k := top().Kind; k == SyntaxPair || k == SyntaxDirective {
+			pop()
+		}
+	}
+
+	tz := NewTokenizer(src)
+	for {
+		tok, err := tz.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		switch tok.Kind {
+		case TokenSection:
+			push(SyntaxSection, tok.Pos.Offset)
+			leaf(tok)
+		case TokenSectionEnd:
+			gap(tok.Pos.Offset)
+			if top().Kind == SyntaxListItem {
+				pop()
+			}
+			pop()
+		case TokenListItem:
+			gap(tok.Pos.Offset)
+			if top().Kind == SyntaxListItem {
+				pop()
+			}
+			push(SyntaxListItem, tok.Pos.Offset)
+			leaf(tok)
+		case TokenKey:
+			push(SyntaxPair, tok.Pos.Offset)
+			leaf(tok)
+		case TokenDirective:
+			push(SyntaxDirective, tok.Pos.Offset)
+			leaf(tok)
+		case TokenValue, TokenAlias:
+			leaf(tok)
+			valueDone()
+		case TokenAnchor:
+			leaf(tok)
+		case TokenArrayStart:
+			push(SyntaxArray, tok.Pos.Offset)
+			leaf(tok)
+		case TokenObjectStart:
+			push(SyntaxObject, tok.Pos.Offset)
+			leaf(tok)
+		case TokenArrayEnd, TokenObjectEnd:
+			leaf(tok)
+			pop()
+			valueDone()
+		case TokenComment:
+			leaf(tok)
+		}
+	}
+
+	gap(len(src))
+	d.root.End = len(src)
+	return nil
+}
+
+// isComment reports whether b starts with a comment.
+func isComment(b []byte) bool {
+	return len(b) > 0 && (b[0] == '#' || bytes.HasPrefix(b, []byte("//")))
+}
@@ -0,0 +1,208 @@
+package rj
+
+import (
+	"testing"
+)
+
+const cstTestInput = `# Service config
+name: "api" # the name
+port: 80
+
+// Database settings
+[Database]
+host: ` + "`db.local`" + `  # primary
+	timeout: 30
+options: {
+	pool: 10
+}
+
+[Servers]
+- host: "a"
+- host: "b"
+`
+
+func leafText(n *SyntaxNode) string {
+	if len(n.Children) == 0 {
+		return n.Text()
+	}
+	text := ""
+	for _, c := range n.Children {
+		text += leafText(c)
+	}
+	return text
+}
+
+func TestParseDocument(t *testing.T) {
+	doc, err := ParseDocument([]byte(cstTestInput))
+	if err != nil {
+		t.Fatal("ParseDocument failed, expected no error, got:", err)
+	}
+
+	if doc.String() != cstTestInput {
+		t.Error("ParseDocument failed, expected the source unchanged, got:", doc.String())
+	}
+
+	if text := leafText(doc.Root()); text != cstTestInput {
+		t.Error("ParseDocument failed, expected the leaves to cover the source, got:", text)
+	}
+
+	db := doc.Root().section("Database")
+	if db == nil || db.Name() != "Database" {
+		t.Fatal("ParseDocument failed, expected section Database")
+	}
+	if host := db.pair("host"); host == nil || host.Value().Text() != "`db.local`" {
+		t.Error("ParseDocument failed, expected pair host with raw string value, got:", host)
+	}
+	if servers := doc.Root().section("Servers"); servers == nil || !servers.isList() {
+		t.Error("ParseDocument failed, expected node list Servers")
+	}
+}
+
+func TestParseDocumentLoneDash(t *testing.T) {
+	in := "[Servers]\n-\n  host: \"a\"\n-\n  host: \"b\"\n"
+	if _, err := ParseString(in); err != nil {
+		t.Fatal("Parse failed, expected no error, got:", err)
+	}
+
+	doc, err := ParseDocument([]byte(in))
+	if err != nil {
+		t.Fatal("ParseDocument failed, expected no error, got:", err)
+	}
+	if doc.String() != in || leafText(doc.Root()) != in {
+		t.Error("ParseDocument failed, expected the source unchanged, got:", doc.String())
+	}
+	servers := doc.Root().section("Servers")
+	if servers == nil || !servers.isList() {
+		t.Fatal("ParseDocument failed, expected node list Servers")
+	}
+	items := 0
+	for _, c := range servers.Children {
+		if c.Kind == SyntaxListItem && c.pair("host") != nil {
+			items++
+		}
+	}
+	if items != 2 {
+		t.Error("ParseDocument failed, expected two list items with a host, got:", items)
+	}
+}
+
+func TestDocument_Set(t *testing.T) {
+	doc, _ := ParseDocument([]byte(cstTestInput))
+
+	cases := []struct {
+		path  string
+		value interface{}
+	}{
+		{path: "port", value: 8080},
+		{path: "Database.host", value: "db.remote"},
+		{path: "Database.options.pool", value: 20},
+		{path: "Database.user", value: "admin"},
+		{path: "Database.options.size", value: 1.5},
+		{path: "debug", value: true},
+	}
+	for _, tc := range cases {
+		if err := doc.Set(tc.path, tc.value); err != nil {
+			t.Error("Set failed, path:", tc.path, ", expected no error, got:", err)
+		}
+	}
+
+	expected := `# Service config
+name: "api" # the name
+port: 8080
+debug: true
+
+// Database settings
+[Database]
+host: "db.remote"  # primary
+	timeout: 30
+options: {
+	pool: 20
+	size: 1.5
+}
+user: "admin"
+
+[Servers]
+- host: "a"
+- host: "b"
+`
+	if doc.String() != expected {
+		t.Error("Set failed, expected:", expected, ", got:", doc.String())
+	}
+
+	node, err := doc.Node()
+	if err != nil || node.GetInt("port") != 8080 {
+		t.Error("Set failed, expected the edited document to parse, got:", err)
+	}
+
+	if err := doc.Set("Servers.host", "c"); err != errTypeMismatch {
+		t.Error("Set failed, expected error (type mismatch) for a node list, got:", err)
+	}
+	if err := doc.Set("missing.key", 1); err != errValueNotFound {
+		t.Error("Set failed, expected error (value not found), got:", err)
+	}
+}
+
+func TestDocument_Delete(t *testing.T) {
+	doc, _ := ParseDocument([]byte(cstTestInput))
+
+	for _, path := range []string{"name", "Database.timeout", "Database.options.pool", "Servers"} {
+		if err := doc.Delete(path); err != nil {
+			t.Error("Delete failed, path:", path, ", expected no error, got:", err)
+		}
+	}
+
+	expected := `# Service config
+port: 80
+
+// Database settings
+[Database]
+host: ` + "`db.local`" + `  # primary
+options: {
+}
+
+`
+	if doc.String() != expected {
+		t.Error("Delete failed, expected:", expected, ", got:", doc.String())
+	}
+
+	if err := doc.Delete("name"); err != errValueNotFound {
+		t.Error("Delete failed, expected error (value not found), got:", err)
+	}
+}
+
+func TestDocument_SetNewRootPair(t *testing.T) {
+	cases := []encodeTestCase{
+		{input: "", expected: "name: \"a\"\n"},
+		{input: "# comment", expected: "# comment\nname: \"a\"\n"},
+		{input: "[Server]\nport: 80\n", expected: "name: \"a\"\n[Server]\nport: 80\n"},
+	}
+
+	for _, tc := range cases {
+		doc, err := ParseDocument([]byte(tc.input.(string)))
+		if err == nil {
+			err = doc.Set("name", "a")
+		}
+		if err != nil || doc.String() != tc.expected {
+			t.Error("Set failed, input:", tc.input, ", expected:", tc.expected, ", got:", doc.String(), err)
+		}
+	}
+}
+
+func TestDocument_SetDottedSection(t *testing.T) {
+	doc, _ := ParseDocument([]byte("[server.tls]\ncert: \"a.pem\"\n"))
+
+	if err := doc.Set("server.tls.cert", "b.pem"); err != nil {
+		t.Error("Set failed, expected no error, got:", err)
+	}
+	if err := doc.Set("server.tls.key", "b.key"); err != nil {
+		t.Error("Set failed, expected no error, got:", err)
+	}
+
+	expected := "[server.tls]\ncert: \"b.pem\"\nkey: \"b.key\"\n"
+	if doc.String() != expected {
+		t.Error("Set failed, expected:", expected, ", got:", doc.String())
+	}
+	if node, err := doc.Node(); err != nil || node.GetString("server.tls.key") != "b.key" {
+		t.Error("Set failed, expected the edited document to parse, got:", err)
+	}
+}
@@ -3,13 +3,12 @@ package rj
 import (
 	"reflect"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 	"unicode/utf8"
 )
 
-const invalidTime = "invalid time value"
-
 var (
 	datetimeFormats = []string{"2006-01-02", "2006-01-02 15:04:05", "15:04:05", time.RFC3339}
 	dateTimeRegs    = []*regexp.Regexp{regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`), //date only
@@ -17,16 +16,94 @@ var (
 		regexp.MustCompile(`^\d{2}:\d{2}:\d{2}(.\d+)?$`),                                       //time only
 		regexp.MustCompile(`^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(.\d+)?(Z|[+-]\d{2}:\d{2})?$`), //RFC3399
 	}
+	durationReg = regexp.MustCompile(`^[-+]?(\d+(\.\d+)?(ns|us|µs|ms|s|m|h))+$`)
 )
 
-func decodeDatetime(raw string) (val time.Time, err error) {
+// isDatetime reports whether raw has the form of a date, time or datetime.
+func isDatetime(raw string) bool {
+	for _, reg := range dateTimeRegs {
+		if reg.MatchString(raw) {
+			return true
+		}
+	}
+	return false
+}
+
+// isDuration reports whether raw has the form of a duration, like 1h30m or 250ms.
+func isDuration(raw string) bool {
+	return durationReg.MatchString(raw)
+}
+
+func decodeDuration(raw string) (time.Duration, error) {
+	d, err := time.ParseDuration(raw)
+	if err != nil {
+		return 0, newError(ErrInvalidDuration)
+	}
+	return d, nil
+}
+
+// decodeNumber decodes an integer or float literal.
+// Integers may have a 0x, 0o or 0b prefix and '_' between digits.
+// They are decoded to an int, or to an int64 or uint64 if they are out of the range of int.
+func decodeNumber(raw string) (val interface{}, err error) {
+	sign, digits := "", raw
+	if len(digits) > 0 && (digits[0] == '+' || digits[0] == '-') {
+		sign, digits = digits[:1], digits[1:]
+	}
+	// a leading zero does not make an octal number
+	for len(digits) > 1 && digits[0] == '0' && digits[1] >= '0' && digits[1] <= '9' {
+		digits = digits[1:]
+	}
+
+	i, err := strconv.ParseInt(sign+digits, 0, 64)
+	if err == nil {
+		if int64(int(i)) == i {
+			return int(i), nil
+		}
+		return i, nil
+	}
+
+	// ParseInt stops at the first digit out of range, which may be in the integer part of a float
+	if err.(*strconv.NumError).Err == strconv.ErrRange && isIntLiteral(digits) {
+		if sign != "-" {
+			if u, err := strconv.ParseUint(digits, 0, 64); err == nil {
+				return u, nil
+			}
+		}
+		return nil, newError(ErrNumberOutOfRange)
+	}
+
+	f, err := strconv.ParseFloat(raw, 64)
+	if err == nil {
+		return f, nil
+	}
+	if err.(*strconv.NumError).Err == strconv.ErrRange {
+		return nil, newError(ErrNumberOutOfRange)
+	}
+	return nil, newError(ErrInvalidNumber)
+}
+
+// isIntLiteral reports whether the digits of a number, without its sign, are those of an integer.
+func isIntLiteral(digits string) bool {
+	if len(digits) > 1 && digits[0] == '0' && strings.IndexByte("xXoObB", digits[1]) >= 0 {
+		return true
+	}
+	return !strings.ContainsAny(digits, ".eEiInN")
+}
+
+// decodeDatetime decodes a date, time or datetime. Those without an offset are in loc.
+func decodeDatetime(raw string, loc *time.Location) (val time.Time, err error) {
 	for i := 0; i < 4; i++ {
 		if dateTimeRegs[i].MatchString(raw) {
-			return time.Parse(datetimeFormats[i], raw)
+			val, err = time.ParseInLocation(datetimeFormats[i], raw, loc)
+			if err != nil {
+				err = newError(ErrInvalidTime)
+			}
+			return
 		}
 	}
 
-	return time.Time{}, newError(invalidTime)
+	return time.Time{}, newError(ErrInvalidTime)
 }
 
 //escape \uXXXX from
@@ -34,7 +111,7 @@ func escapeU4(in []byte) (out []byte, size int, err error) {
 	var r rune
 
 	if len(in) != 4 {
-		err = newError(invalidUTF8StringValue)
+		err = newError(ErrInvalidUTF8String)
 		return
 	}
 	for i := 0; i < 4; i++ {
@@ -47,7 +124,7 @@ func escapeU4(in []byte) (out []byte, size int, err error) {
 		case 'A' <= c && c <= 'F':
 			c = c - 'A' + 10
 		default:
-			err = newError(invalidUTF8StringValue)
+			err = newError(ErrInvalidUTF8String)
 			return
 		}
 		r = r*16 + rune(c)
@@ -59,13 +136,15 @@ func escapeU4(in []byte) (out []byte, size int, err error) {
 }
 
 // decode a node to a field of type of struct or pointer to a struct
-func decodeStructField(n *Node, ft reflect.Type) (v reflect.Value) {
+func decodeStructField(n *Node, ft reflect.Type) (v reflect.Value, err error) {
 	if ft.Kind() == reflect.Struct {
 		v = reflect.New(ft).Elem()
-		decodeNode(n, v)
+		err = decodeNode(n, v)
 	} else if ft.Kind() == reflect.Ptr {
 		v = reflect.New(ft.Elem())
-		decodeNode(n, v.Elem())
+		err = decodeNode(n, v.Elem())
+	} else {
+		err = errTypeMismatch
 	}
 	return
 }
@@ -81,97 +160,173 @@ func decodeNode(n *Node, rv reflect.Value) error {
 		if !field.IsValid() {
 			field = rv.FieldByName(strings.Title(k))
 		}
-		if !field.IsValid() {
+		if !field.IsValid() || !field.CanSet() {
 			continue
 		}
 
-		switch vt := v.(type) {
-		case string:
-			if field.Kind() != reflect.String {
-				return errTypeMismatch
-			}
-			field.SetString(vt)
-		case int:
-			field.SetInt(int64(vt))
-		case bool:
-			field.SetBool(vt)
-		case float64:
-			field.SetFloat(vt)
-		case time.Time:
-			field.Set(reflect.ValueOf(vt))
-		case *Node:
-			s := decodeStructField(vt, field.Type())
-			field.Set(s)
-		case []string:
-			l := len(vt)
-			if l == 0 {
-				return nil
-			}
+		if err := decodeValue(v, field); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-			arr := reflect.MakeSlice(field.Type(), l, l)
-			for i := 0; i < l; i++ {
-				arr.Index(i).Set(reflect.ValueOf(vt[i]))
-			}
-			field.Set(arr)
-		case []int:
-			l := len(vt)
-			if l == 0 {
-				return nil
-			}
+// decodeValue decodes a value of a node to a field.
+func decodeValue(v interface{}, field reflect.Value) error {
+	if v == nil {
+		field.Set(reflect.Zero(field.Type()))
+		return nil
+	}
 
-			arr := reflect.MakeSlice(field.Type(), l, l)
-			for i := 0; i < l; i++ {
-				arr.Index(i).Set(reflect.ValueOf(vt[i]))
-			}
-			field.Set(arr)
-		case []bool:
-			l := len(vt)
-			if l == 0 {
-				return nil
-			}
+	ft := field.Type()
+	if ft.Kind() == reflect.Interface {
+		rv := reflect.ValueOf(v)
+		if !rv.Type().AssignableTo(ft) {
+			return errTypeMismatch
+		}
+		field.Set(rv)
+		return nil
+	}
 
-			arr := reflect.MakeSlice(field.Type(), l, l)
-			for i := 0; i < l; i++ {
-				arr.Index(i).Set(reflect.ValueOf(vt[i]))
-			}
-			field.Set(arr)
-		case []float64:
-			l := len(vt)
-			if l == 0 {
-				return nil
+	if ft.Kind() == reflect.Ptr && ft != nodeType {
+		if _, ok := v.(*Node); ok {
+			s, err := decodeStructField(v.(*Node), ft)
+			if err == nil {
+				field.Set(s)
 			}
+			return err
+		}
 
-			arr := reflect.MakeSlice(field.Type(), l, l)
-			for i := 0; i < l; i++ {
-				arr.Index(i).Set(reflect.ValueOf(vt[i]))
-			}
-			field.Set(arr)
-		case []time.Time:
-			l := len(vt)
-			if l == 0 {
-				return nil
-			}
+		p := reflect.New(ft.Elem())
+		if err := decodeValue(v, p.Elem()); err != nil {
+			return err
+		}
+		field.Set(p)
+		return nil
+	}
 
-			arr := reflect.MakeSlice(field.Type(), l, l)
-			for i := 0; i < l; i++ {
-				arr.Index(i).Set(reflect.ValueOf(vt[i]))
-			}
-			field.Set(arr)
-		case []*Node:
-			l := len(vt)
-			if l == 0 {
-				return nil
-			}
+	switch vt := v.(type) {
+	case string:
+		if ft.Kind() != reflect.String {
+			return errTypeMismatch
+		}
+		field.SetString(vt)
+	case int:
+		return decodeInt(int64(vt), field)
+	case int64:
+		return decodeInt(vt, field)
+	case uint64:
+		return decodeUint(vt, field)
+	case bool:
+		if ft.Kind() != reflect.Bool {
+			return errTypeMismatch
+		}
+		field.SetBool(vt)
+	case float64:
+		if ft.Kind() != reflect.Float32 && ft.Kind() != reflect.Float64 {
+			return errTypeMismatch
+		}
+		field.SetFloat(vt)
+	case time.Time:
+		if ft != timeType {
+			return errTypeMismatch
+		}
+		field.Set(reflect.ValueOf(vt))
+	case time.Duration:
+		if ft != durationType {
+			return errTypeMismatch
+		}
+		field.SetInt(int64(vt))
+	case ByteSize:
+		if ft == durationType {
+			return errTypeMismatch
+		}
+		return decodeInt(int64(vt), field)
+	case *Node:
+		if ft == nodeType {
+			field.Set(reflect.ValueOf(vt))
+			return nil
+		}
+		s, err := decodeStructField(vt, ft)
+		if err != nil {
+			return err
+		}
+		field.Set(s)
+	default:
+		return decodeArray(v, field)
+	}
+	return nil
+}
 
-			arr := reflect.MakeSlice(field.Type(), l, l)
+// decodeInt decodes an int to an int, uint or float field.
+// A time.Duration field is an error: a bare number has no unit, a duration is written like 30s.
+func decodeInt(i int64, field reflect.Value) error {
+	if field.Type() == durationType {
+		return errTypeMismatch
+	}
 
-			for i := 0; i < l; i++ {
-				e := decodeStructField(vt[i], field.Type().Elem())
-				arr.Index(i).Set(e)
-			}
-			field.Set(arr)
+	switch field.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if field.OverflowInt(i) {
+			return errTypeMismatch
+		}
+		field.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if i < 0 || field.OverflowUint(uint64(i)) {
+			return errTypeMismatch
+		}
+		field.SetUint(uint64(i))
+	case reflect.Float32, reflect.Float64:
+		field.SetFloat(float64(i))
+	default:
+		return errTypeMismatch
+	}
+	return nil
+}
+
+// decodeUint decodes an uint64 out of the range of int64 to an uint or float field.
+func decodeUint(u uint64, field reflect.Value) error {
+	switch field.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if field.OverflowUint(u) {
+			return errTypeMismatch
+		}
+		field.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		field.SetFloat(float64(u))
+	default:
+		return errTypeMismatch
+	}
+	return nil
+}
+
+// decodeArray decodes an array, typed or []interface{}, to a slice or array field.
+func decodeArray(v interface{}, field reflect.Value) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice {
+		return errTypeMismatch
+	}
+
+	l := rv.Len()
+	var arr reflect.Value
+	switch field.Kind() {
+	case reflect.Slice:
+		arr = reflect.MakeSlice(field.Type(), l, l)
+	case reflect.Array:
+		if field.Len() != l {
+			return errTypeMismatch
+		}
+		arr = reflect.New(field.Type()).Elem()
+	default:
+		return errTypeMismatch
+	}
+
+	for i := 0; i < l; i++ {
+		if err := decodeValue(rv.Index(i).Interface(), arr.Index(i)); err != nil {
+			return err
 		}
 	}
+	field.Set(arr)
 	return nil
 }
 
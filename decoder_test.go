@@ -1,6 +1,8 @@
 package rj
 
 import (
+	"math"
+	"reflect"
 	"testing"
 	"time"
 )
@@ -12,7 +14,7 @@ type testCase struct {
 }
 
 func TestDecodeDatetime(t *testing.T) {
-	loc, _ := time.LoadLocation("Asia/shanghai")
+	loc, _ := time.LoadLocation("Asia/Shanghai")
 	tests := []testCase{
 		{input: "2018-08-08", expected: time.Date(2018, time.August, 8, 0, 0, 0, 0, time.UTC)},
 		{input: "2019-09-09 13:54:46", expected: time.Date(2019, time.September, 9, 13, 54, 46, 0, time.UTC)},
@@ -22,7 +24,7 @@ func TestDecodeDatetime(t *testing.T) {
 	}
 
 	for _, tc := range tests {
-		tm, _ := decodeDatetime(tc.input)
+		tm, _ := decodeDatetime(tc.input, time.UTC)
 		if !tm.Equal(tc.expected.(time.Time)) {
 			t.Error("Decode time failed:", tc.input, "expected:", tc.expected, "actual:", tm)
 		}
@@ -188,3 +190,76 @@ func TestDecodeNested(t *testing.T) {
 		t.Error("Decode array of sub notes failed. expected:", expected.Children, ", got: ", decoded)
 	}
 }
+
+func TestDecodeMixedArray(t *testing.T) {
+	type ts struct {
+		Matrix [][]float64
+		Pair   [2]int
+		Any    []interface{}
+		Ptrs   []*int
+		Small  int8
+		Count  uint
+	}
+	in := `Matrix: [[1, 2.5], [3]]
+Pair: [4, 5]
+Any: [1, "a", null]
+Ptrs: [1, null]
+Small: 12
+Count: 3
+`
+	v := new(ts)
+	err := Unmarshal([]byte(in), v)
+	if err != nil {
+		t.Fatal("Decode mixed array failed, err:", err)
+	}
+
+	expected := ts{
+		Matrix: [][]float64{{1, 2.5}, {3}},
+		Pair:   [2]int{4, 5},
+		Any:    []interface{}{1, "a", nil},
+		Small:  12,
+		Count:  3,
+	}
+	if !reflect.DeepEqual(v.Matrix, expected.Matrix) || v.Pair != expected.Pair ||
+		!reflect.DeepEqual(v.Any, expected.Any) || v.Small != expected.Small || v.Count != expected.Count {
+		t.Errorf("Decode mixed array failed, expected: %+v, got: %+v", expected, *v)
+	}
+	if len(v.Ptrs) != 2 || v.Ptrs[0] == nil || *v.Ptrs[0] != 1 || v.Ptrs[1] != nil {
+		t.Error("Decode array of pointers failed, got:", v.Ptrs)
+	}
+
+	var big struct {
+		Max  uint64
+		Hex  int16
+		Mask uint8
+	}
+	err = Unmarshal([]byte("Max: 18446744073709551615\nHex: -0x7FFF\nMask: 0b1111_0000\n"), &big)
+	if err != nil || big.Max != math.MaxUint64 || big.Hex != -0x7FFF || big.Mask != 0xF0 {
+		t.Error("Decode extended numbers failed, got:", big, err)
+	}
+
+	var timeouts struct {
+		Read  time.Duration
+		Write *time.Duration
+		Retry []time.Duration
+	}
+	err = Unmarshal([]byte("Read: 1h30m\nWrite: 250ms\nRetry: [1s, 2s]\n"), &timeouts)
+	if err != nil || timeouts.Read != 90*time.Minute || timeouts.Write == nil || *timeouts.Write != 250*time.Millisecond ||
+		!reflect.DeepEqual(timeouts.Retry, []time.Duration{time.Second, 2 * time.Second}) {
+		t.Error("Decode durations failed, got:", timeouts, err)
+	}
+	if err = Unmarshal([]byte("Small: 1s"), v); err != errTypeMismatch {
+		t.Error("Decode duration to int failed, expected error (type mismatch), got:", err)
+	}
+	if err = Unmarshal([]byte("Read: 30"), &timeouts); err != errTypeMismatch {
+		t.Error("Decode int to duration failed, expected error (type mismatch), got:", err)
+	}
+	if err = Unmarshal([]byte("Retry: [1s, 2]"), &timeouts); err != errTypeMismatch {
+		t.Error("Decode int to duration array failed, expected error (type mismatch), got:", err)
+	}
+
+	err = Unmarshal([]byte("Small: 300"), v)
+	if err != errTypeMismatch {
+		t.Error("Decode overflowing int failed, expected error (type mismatch), got:", err)
+	}
+}
@@ -0,0 +1,236 @@
+package rj
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ChangeKind is the kind of a Change.
+type ChangeKind int
+
+const (
+	Added   ChangeKind = iota + 1 // the value is only in the new node
+	Removed                       // the value is only in the old node
+	Changed                       // the value is in both nodes, and is different
+)
+
+var changeKindNames = map[ChangeKind]string{
+	Added:   "added",
+	Removed: "removed",
+	Changed: "changed",
+}
+
+func (k ChangeKind) String() string {
+	return changeKindNames[k]
+}
+
+// Change is a difference between two nodes.
+type Change struct {
+	Kind ChangeKind
+
+	// Path is the query path of the value, like "db.host", "servers[1]"
+	// or `servers[?(@.name=="b")].port` for a node list matched by key. See Node.Query.
+	// The index of a removed node is its index in the old list, the other indexes are in the new list.
+	Path string
+
+	Old interface{} // the old value, nil if the value is added
+	New interface{} // the new value, nil if the value is removed
+}
+
+// String formats the change for people to read: the path and the value of an added value after '+',
+// of a removed value after '-', and the old and new values of a changed value after '~', like
+// ~ db.host: "localhost" -> "db.prod". A node is written as an object, on several lines.
+func (c Change) String() string {
+	switch c.Kind {
+	case Added:
+		return "+ " + c.Path + ": " + diffText(c.New)
+	case Removed:
+		return "- " + c.Path + ": " + diffText(c.Old)
+	}
+	return "~ " + c.Path + ": " + diffText(c.Old) + " -> " + diffText(c.New)
+}
+
+// diffText formats a value of a change, the lines of a node are indented.
+func diffText(v interface{}) string {
+	text, err := marshalValue(v)
+	if err != nil {
+		return "?"
+	}
+	return strings.Replace(string(text), "\n", "\n  ", -1)
+}
+
+// FormatDiff formats changes for people to read, each change starting on its own line.
+func FormatDiff(changes []Change) string {
+	var b strings.Builder
+	for _, c := range changes {
+		b.WriteString(c.String())
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// DiffOptions controls how Diff compares two nodes.
+type DiffOptions struct {
+	// Keys are the keys which identify the nodes of the node lists at dot separated paths,
+	// like {"servers": "name"}, so a node is compared with the node of the same key wherever it is.
+	// A path does not include indexes, like the paths of MergeOptions.Rules.
+	// The nodes of the other lists, and the nodes without the key, are compared by index.
+	Keys map[string]string
+}
+
+// Diff returns the changes from the node a to the node b: the values which are added, removed or changed.
+// Nodes are compared key by key, whatever the order of their keys, and node lists item by item.
+// Other arrays are compared as a whole. The changes are in the order of the keys of a,
+// followed by the keys which are only in b. A nil node is empty.
+func Diff(a, b *Node) []Change {
+	return DiffWithOptions(a, b, DiffOptions{})
+}
+
+// DiffWithOptions returns the changes from the node a to the node b, like Diff.
+func DiffWithOptions(a, b *Node, opts DiffOptions) []Change {
+	// every key of the other node is added or removed
+	if a == nil {
+		a = NewNode()
+	}
+	if b == nil {
+		b = NewNode()
+	}
+
+	d := &differ{opts: opts}
+	d.diffNodes(a, b, "", "")
+	return d.changes
+}
+
+type differ struct {
+	opts    DiffOptions
+	changes []Change
+}
+
+func (d *differ) add(kind ChangeKind, path string, old, new interface{}) {
+	d.changes = append(d.changes, Change{Kind: kind, Path: path, Old: old, New: new})
+}
+
+// diffNodes compares two nodes. path is the query path of the nodes,
+// keyPath is their path without indexes, to find the keys of their node lists.
+func (d *differ) diffNodes(a, b *Node, path, keyPath string) {
+	join := func(p, k string) string {
+		if p == "" {
+			return k
+		}
+		return p + "." + k
+	}
+
+	for _, k := range a.keys {
+		av := a.dict[k]
+		if bv, ok := b.dict[k]; ok {
+			d.diffValues(av, bv, join(path, k), join(keyPath, k))
+		} else {
+			d.add(Removed, join(path, k), av, nil)
+		}
+	}
+	for _, k := range b.keys {
+		if _, ok := a.dict[k]; !ok {
+			d.add(Added, join(path, k), nil, b.dict[k])
+		}
+	}
+}
+
+func (d *differ) diffValues(av, bv interface{}, path, keyPath string) {
+	switch at := av.(type) {
+	case *Node:
+		if bt, ok := bv.(*Node); ok {
+			d.diffNodes(at, bt, path, keyPath)
+			return
+		}
+	case []*Node:
+		if bt, ok := bv.([]*Node); ok {
+			d.diffLists(at, bt, path, keyPath)
+			return
+		}
+	}
+
+	if !equalValues(av, bv) {
+		d.add(Changed, path, av, bv)
+	}
+}
+
+// diffLists compares two node lists. The nodes compared by index are in the path by their index in bl,
+// as the changes in them apply to bl, and the nodes removed from al by their index in al.
+func (d *differ) diffLists(al, bl []*Node, path, keyPath string) {
+	key := d.opts.Keys[keyPath]
+	matched := make(map[*Node]bool)
+	var unmatched []*Node // the nodes of a compared by index
+
+	for _, an := range al {
+		if key == "" || an.dict[key] == nil {
+			unmatched = append(unmatched, an)
+			continue
+		}
+		itemPath := keyedPath(path, key, an)
+		if bn := findByKey(bl, key, an); bn != nil && !matched[bn] {
+			matched[bn] = true
+			d.diffNodes(an, bn, itemPath, keyPath)
+		} else {
+			d.add(Removed, itemPath, an, nil)
+		}
+	}
+
+	i := 0
+	for j, bn := range bl {
+		if matched[bn] {
+			continue
+		}
+		itemPath := path + "[" + strconv.Itoa(j) + "]"
+		if key != "" && bn.dict[key] != nil {
+			itemPath = keyedPath(path, key, bn)
+			d.add(Added, itemPath, nil, bn)
+			continue
+		}
+		if i < len(unmatched) {
+			d.diffNodes(unmatched[i], bn, itemPath, keyPath)
+			i++
+		} else {
+			d.add(Added, itemPath, nil, bn)
+		}
+	}
+	for ; i < len(unmatched); i++ {
+		d.add(Removed, path+"["+strconv.Itoa(indexOf(al, unmatched[i]))+"]", unmatched[i], nil)
+	}
+}
+
+// keyedPath returns the query path of the node of a list which is matched by key.
+func keyedPath(path, key string, n *Node) string {
+	return path + "[?(@." + key + "==" + diffText(n.dict[key]) + ")]"
+}
+
+func indexOf(list []*Node, n *Node) int {
+	for i, item := range list {
+		if item == n {
+			return i
+		}
+	}
+	return -1
+}
+
+// equalValues reports whether two values are equal. Times are equal if they are the same instant.
+func equalValues(a, b interface{}) bool {
+	switch at := a.(type) {
+	case time.Time:
+		bt, ok := b.(time.Time)
+		return ok && at.Equal(bt)
+	case []time.Time:
+		bt, ok := b.([]time.Time)
+		if !ok || len(at) != len(bt) {
+			return false
+		}
+		for i := range at {
+			if !at[i].Equal(bt[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return reflect.DeepEqual(a, b)
+}
@@ -0,0 +1,98 @@
+package rj
+
+import "testing"
+
+func TestDiff(t *testing.T) {
+	a, _ := ParseString(`name: "api"
+debug: true
+ports: [80, 443]
+at: 2019-10-11T12:00:00Z
+db: {host: "localhost"
+	port: 5432}
+
+[servers]
+- name: "a"
+  port: 80
+- name: "b"
+  port: 81
+`)
+	b, _ := ParseString(`level: 2
+at: 2019-10-11T14:00:00+02:00
+db: {port: 5432
+	host: "db.prod"}
+ports: [80, 443, 8080]
+name: "api"
+
+[servers]
+- name: "b"
+  port: 8081
+- name: "c"
+  port: 82
+`)
+
+	expected := `- debug: true
+~ ports: [80,443] -> [80,443,8080]
+~ db.host: "localhost" -> "db.prod"
+~ servers[0].name: "a" -> "b"
+~ servers[0].port: 80 -> 8081
+~ servers[1].name: "b" -> "c"
+~ servers[1].port: 81 -> 82
++ level: 2
+`
+	changes := Diff(a, b)
+	if text := FormatDiff(changes); text != expected {
+		t.Error("Diff failed, expected:", expected, ", got:", text)
+	}
+	if c := changes[2]; c.Kind != Changed || c.Old != "localhost" || c.New != "db.prod" {
+		t.Error("Diff failed, expected a changed value, got:", c)
+	}
+	for _, c := range changes {
+		if c.Kind != Added {
+			if v, _ := a.Query(c.Path); len(v) != 1 {
+				t.Error("Diff failed, expected a query path of a, got:", c.Path)
+			}
+		}
+	}
+
+	expected = `- servers[?(@.name=="a")]: {
+  	name: "a"
+  	port: 80
+  }
+~ servers[?(@.name=="b")].port: 81 -> 8081
++ servers[?(@.name=="c")]: {
+  	name: "c"
+  	port: 82
+  }
+`
+	aList, _ := a.Query("servers")
+	bList, _ := b.Query("servers")
+	x, y := NewNode(), NewNode()
+	x.Set("servers", aList[0])
+	y.Set("servers", bList[0])
+	changes = DiffWithOptions(x, y, DiffOptions{Keys: map[string]string{"servers": "name"}})
+	if text := FormatDiff(changes); text != expected {
+		t.Error("Diff by key failed, expected:", expected, ", got:", text)
+	}
+	if v, _ := y.Query(changes[1].Path); len(v) != 1 || v[0] != 8081 {
+		t.Error("Diff by key failed, expected a query path, got:", changes[1].Path, v)
+	}
+
+	if changes = Diff(a, a); len(changes) != 0 {
+		t.Error("Diff failed, expected no change, got:", changes)
+	}
+
+	if changes = Diff(nil, a); len(changes) != len(a.Keys()) || changes[0].Kind != Added || changes[0].Path != "name" {
+		t.Error("Diff with a nil node failed, expected every key added, got:", changes)
+	}
+	if changes = Diff(a, nil); len(changes) != len(a.Keys()) || changes[0].Kind != Removed {
+		t.Error("Diff with a nil node failed, expected every key removed, got:", changes)
+	}
+
+	// the changed node is at its index in the new list, the removed one at its index in the old list
+	x, _ = ParseString("[l]\n- name: \"a\"\n- v: 1\n- v: 2\n")
+	y, _ = ParseString("[l]\n- v: 5\n")
+	expected = "- l[?(@.name==\"a\")]: {\n  \tname: \"a\"\n  }\n~ l[0].v: 1 -> 5\n- l[2]: {\n  \tv: 2\n  }\n"
+	if text := FormatDiff(DiffWithOptions(x, y, DiffOptions{Keys: map[string]string{"l": "name"}})); text != expected {
+		t.Error("Diff of lists failed, expected:", expected, ", got:", text)
+	}
+}
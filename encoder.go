@@ -1,52 +1,82 @@
 package rj
 
 import (
+	"bufio"
 	"bytes"
+	"math"
 	"reflect"
 	"strconv"
+	"strings"
 	"time"
+	"unicode/utf8"
+)
+
+// An UnsupportedTypeError is returned when encoding a value of a type RJ cannot represent.
+type UnsupportedTypeError struct {
+	Type reflect.Type
+}
+
+func (e *UnsupportedTypeError) Error() string {
+	return "rj: unsupported type: " + e.Type.String()
+}
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	durationType = reflect.TypeOf(time.Duration(0))
+	byteSizeType = reflect.TypeOf(ByteSize(0))
+	nodeType     = reflect.TypeOf((*Node)(nil))
 )
 
 type encoder struct {
-	val interface{}
-	*bytes.Buffer
+	*bufio.Writer
+	depth int // depth of nested objects, for indentation
+
+	refs    map[pointer]int    // number of times each pointer is found, when anchors are written
+	anchors map[pointer]string // names of the anchors written
 }
 
-func newEncoder(v interface{}) *encoder {
-	return &encoder{v, new(bytes.Buffer)}
+// pointer identifies the value a pointer points to.
+type pointer struct {
+	t reflect.Type
+	p uintptr
 }
 
-func (e *encoder) encode() []byte {
-	rv := reflect.ValueOf(e.val)
-	if rv.Kind() == reflect.Ptr {
-		rv = rv.Elem()
-	}
+func newEncoder(w *bufio.Writer) *encoder {
+	return &encoder{Writer: w}
+}
 
-	if rv.Kind() == reflect.Struct {
-		vt := rv.Type()
-		if vt.Name() == "Time" {
-			e.encodeTime(rv)
-		} else {
-			e.encodeFields(rv, vt)
-		}
-	} else {
-		e.encodeVal(rv)
+// marshalValue encodes a single value, a struct is encoded as an object.
+func marshalValue(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	w := bufio.NewWriter(&buf)
+	if err := newEncoder(w).encodeVal(reflect.ValueOf(v)); err != nil {
+		return nil, err
 	}
-	return e.Bytes()
+	w.Flush()
+	return buf.Bytes(), nil
 }
 
-func (e *encoder) doEncode() []byte {
-	rv := reflect.ValueOf(e.val)
-	e.encodeVal(rv)
-	return e.Bytes()
+// encode writes v; a struct or node at the top level is written as a list of pairs.
+func (e *encoder) encode(v interface{}) error {
+	if n, ok := v.(*Node); ok && n != nil {
+		return e.encodeNodePairs(n, true)
+	}
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+
+	if rv.Kind() == reflect.Struct && rv.Type() != timeType {
+		return e.encodeFields(rv)
+	}
+	return e.encodeVal(rv)
 }
 
-func (e *encoder) encodeVal(v reflect.Value) {
+func (e *encoder) encodeVal(v reflect.Value) error {
 	switch v.Kind() {
 	case reflect.String:
-		e.WriteByte('"')
-		e.WriteString(v.String())
-		e.WriteByte('"')
+		e.encodeString(v.String())
 	case reflect.Bool:
 		if v.Bool() {
 			e.WriteString("true")
@@ -54,72 +84,394 @@ func (e *encoder) encodeVal(v reflect.Value) {
 			e.WriteString("false")
 		}
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-		b := strconv.AppendInt([]byte{}, v.Int(), 10)
-		e.Write(b)
+		switch v.Type() {
+		case durationType:
+			e.WriteString(time.Duration(v.Int()).String())
+			return nil
+		case byteSizeType:
+			e.WriteString(ByteSize(v.Int()).String())
+			return nil
+		}
+		e.WriteString(strconv.FormatInt(v.Int(), 10))
 	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
-		b := strconv.AppendUint([]byte{}, v.Uint(), 10)
-		e.Write(b)
+		e.WriteString(strconv.FormatUint(v.Uint(), 10))
 	case reflect.Float32, reflect.Float64:
-		b := strconv.AppendFloat([]byte{}, v.Float(), 'f', -1, 64)
-		e.Write(b)
+		e.encodeFloat(v.Float())
 	case reflect.Struct:
-		e.encodeStruct(v)
+		if v.Type() == timeType {
+			e.encodeTime(v)
+			return nil
+		}
+		return e.encodeStruct(v)
 	case reflect.Slice, reflect.Array:
-		e.encodeArray(v)
+		return e.encodeArray(v)
 	case reflect.Interface, reflect.Ptr:
 		if v.IsNil() {
 			e.WriteString("null")
+		} else if v.Kind() == reflect.Ptr && e.writeAnchor(v) {
+			return nil
+		} else if v.Type() == nodeType {
+			return e.encodeNode(v.Interface().(*Node))
 		} else {
-			e.encodeVal(v.Elem())
+			return e.encodeVal(v.Elem())
+		}
+	case reflect.Invalid:
+		e.WriteString("null")
+	default:
+		return &UnsupportedTypeError{v.Type()}
+	}
+	return nil
+}
+
+// countRefs counts the pointers found in v, so that a value found more than once is written with an anchor.
+// The value of a pointer is walked the first time only.
+func (e *encoder) countRefs(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return
+		}
+		p := pointer{v.Type(), v.Pointer()}
+		e.refs[p]++
+		if e.refs[p] > 1 {
+			return
+		}
+		if v.Type() == nodeType {
+			n := v.Interface().(*Node)
+			for _, k := range n.keys {
+				e.countRefs(reflect.ValueOf(n.dict[k]))
+			}
+			return
+		}
+		e.countRefs(v.Elem())
+	case reflect.Interface:
+		if !v.IsNil() {
+			e.countRefs(v.Elem())
+		}
+	case reflect.Struct:
+		vt := v.Type()
+		for i := 0; i < v.NumField(); i++ {
+			if vt.Field(i).PkgPath == "" {
+				e.countRefs(v.Field(i))
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			e.countRefs(v.Index(i))
 		}
 	}
 }
 
+// writeAnchor writes the anchor of a pointer found more than once, and returns false,
+// or writes an alias and returns true if the anchor is already written.
+func (e *encoder) writeAnchor(v reflect.Value) bool {
+	p := pointer{v.Type(), v.Pointer()}
+	if e.refs[p] < 2 {
+		return false
+	}
+	if name, ok := e.anchors[p]; ok {
+		e.WriteString("*" + name)
+		return true
+	}
+
+	name := "a" + strconv.Itoa(len(e.anchors)+1)
+	e.anchors[p] = name
+	e.WriteString("&" + name + " ")
+	return false
+}
+
+// isShared reports whether a node, or a node of a list, is found more than once.
+// It is then written as a value, since a section cannot have an anchor.
+func (e *encoder) isShared(v interface{}) bool {
+	switch vt := v.(type) {
+	case *Node:
+		return e.refs[pointer{nodeType, reflect.ValueOf(vt).Pointer()}] > 1
+	case []*Node:
+		for _, n := range vt {
+			if e.isShared(n) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// encodeString writes s as a quoted string, or between triple quotes if it has line ends.
+func (e *encoder) encodeString(s string) {
+	if strings.Contains(s, "\n") {
+		e.encodeMultilineString(s)
+		return
+	}
+
+	e.WriteByte('"')
+	e.writeEscaped(s, false)
+	e.WriteByte('"')
+}
+
+// encodeMultilineString writes s between triple quotes, one line of s per line,
+// indented one level deeper than the pair.
+func (e *encoder) encodeMultilineString(s string) {
+	e.WriteString(`"""`)
+	if strings.HasSuffix(s, "\n") {
+		// kept by the line end before the closing quotes
+		s = s[:len(s)-1]
+	} else {
+		e.WriteByte('-')
+	}
+	e.WriteByte('\n')
+
+	e.depth++
+	for _, line := range strings.Split(s, "\n") {
+		if line != "" {
+			e.writeIndent()
+			e.writeEscaped(line, true)
+		}
+		e.WriteByte('\n')
+	}
+	e.writeIndent()
+	e.depth--
+	e.WriteString(`"""`)
+}
+
+// writeEscaped writes s with the escapes of a quoted string, and ${ as $${ so it is not read as a reference.
+// In a multi-line string, tabs are kept and only quotes that would close the string are escaped.
+func (e *encoder) writeEscaped(s string, multiline bool) {
+	start := 0
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c >= utf8.RuneSelf {
+			_, size := utf8.DecodeRuneInString(s[i:])
+			i += size
+			continue
+		}
+
+		var esc string
+		switch c {
+		case '"':
+			if multiline && !strings.HasPrefix(s[i+1:], `""`) {
+				i++
+				continue
+			}
+			esc = `\"`
+		case '\\':
+			esc = `\\`
+		case '$':
+			// ${ would be read as a reference, $${ is a literal ${
+			if !strings.HasPrefix(s[i+1:], "{") {
+				i++
+				continue
+			}
+			esc = "$$"
+		case '\n':
+			esc = `\n`
+		case '\r':
+			esc = `\r`
+		case '\t':
+			if multiline {
+				i++
+				continue
+			}
+			esc = `\t`
+		case '\b':
+			esc = `\b`
+		case '\f':
+			esc = `\f`
+		default:
+			if c >= 0x20 {
+				i++
+				continue
+			}
+			esc = `\u00` + strconv.FormatUint(uint64(c)>>4, 16) + strconv.FormatUint(uint64(c)&0xF, 16)
+		}
+
+		e.WriteString(s[start:i])
+		e.WriteString(esc)
+		i++
+		start = i
+	}
+	e.WriteString(s[start:])
+}
+
+// encodeFloat writes f so that it is scanned back as a float, not an int.
+func (e *encoder) encodeFloat(f float64) {
+	switch {
+	case math.IsInf(f, 1):
+		e.WriteString("inf")
+		return
+	case math.IsInf(f, -1):
+		e.WriteString("-inf")
+		return
+	case math.IsNaN(f):
+		e.WriteString("nan")
+		return
+	}
+
+	format := byte('f')
+	if abs := math.Abs(f); abs != 0 && (abs < 1e-6 || abs >= 1e21) {
+		// an exponent keeps very large and very small numbers short
+		format = 'e'
+	}
+	s := strconv.FormatFloat(f, format, -1, 64)
+	if !strings.ContainsAny(s, ".eEnN") {
+		s += ".0"
+	}
+	e.WriteString(s)
+}
+
 func (e *encoder) encodeTime(v reflect.Value) {
 	t := v.Interface().(time.Time)
-	b := make([]byte, 0, len(time.RFC3339Nano))
-	e.Write(t.AppendFormat(b, time.RFC3339Nano))
+	e.WriteString(t.Format(time.RFC3339Nano))
 }
 
-func (e *encoder) encodeStruct(v reflect.Value) {
-	vt := v.Type()
-
-	e.WriteString(vt.Name())
-	e.WriteByte(':')
-	e.WriteByte('{')
+// encodeStruct writes a struct as an object.
+func (e *encoder) encodeStruct(v reflect.Value) error {
+	e.WriteString("{\n")
+	e.depth++
 
-	e.encodeFields(v, vt)
+	err := e.encodeFields(v)
 
+	e.depth--
+	e.writeIndent()
 	e.WriteByte('}')
+	return err
 }
 
-func (e *encoder) encodeFields(v reflect.Value, vt reflect.Type) {
+// encodeFields writes the exported fields of a struct, one pair per line.
+func (e *encoder) encodeFields(v reflect.Value) error {
+	vt := v.Type()
 	n := v.NumField()
 	for i := 0; i < n; i++ {
-		f := v.Field(i)
-		ft := f.Type()
+		sf := vt.Field(i)
+		if sf.PkgPath != "" {
+			// unexported
+			continue
+		}
 
-		if ft.Name() == "" && ft.Kind() == reflect.Ptr {
-			//ft = ft.Elem()
-			f = f.Elem()
+		e.writeIndent()
+		e.WriteString(sf.Name)
+		e.WriteString(": ")
+		if err := e.encodeVal(v.Field(i)); err != nil {
+			return err
 		}
+		e.WriteByte('\n')
+	}
+	return nil
+}
+
+// encodeNode writes a node as an object.
+func (e *encoder) encodeNode(n *Node) error {
+	e.WriteString("{\n")
+	e.depth++
+
+	err := e.encodeNodePairs(n, false)
+
+	e.depth--
+	e.writeIndent()
+	e.WriteByte('}')
+	return err
+}
 
-		e.WriteString(vt.Field(i).Name)
+// encodeNodePairs writes the pairs of a node in the order of its keys.
+// At the top level, sub-nodes and node lists are written as sections.
+func (e *encoder) encodeNodePairs(n *Node, top bool) error {
+	pairs := false // whether pairs are written since the last section
+	for _, k := range n.keys {
+		v := n.dict[k]
+		if top && isSection(v) && !e.isShared(v) {
+			if pairs {
+				e.WriteByte('\n')
+			}
+			pairs = false
+
+			var err error
+			if list, ok := v.([]*Node); ok {
+				err = e.encodeSectionList(k, list)
+			} else {
+				err = e.encodeSection(k, v.(*Node))
+			}
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		pairs = true
+		e.writeIndent()
+		e.WriteString(k)
 		e.WriteString(": ")
-		e.encodeVal(f)
+		if err := e.encodeVal(reflect.ValueOf(v)); err != nil {
+			return err
+		}
 		e.WriteByte('\n')
+	}
+	return nil
+}
 
+// isSection reports whether a value is written as a section at the top level.
+// Empty nodes and node lists are written as values, since an empty section would take the lines after it.
+func isSection(v interface{}) bool {
+	switch vt := v.(type) {
+	case *Node:
+		return vt != nil && len(vt.keys) > 0
+	case []*Node:
+		return len(vt) > 0
 	}
+	return false
 }
 
-func (e *encoder) encodeArray(v reflect.Value) {
+// encodeSection writes a node as a section, ended by a blank line.
+func (e *encoder) encodeSection(name string, n *Node) error {
+	e.WriteString("[" + name + "]\n")
+	if err := e.encodeNodePairs(n, false); err != nil {
+		return err
+	}
+	e.WriteByte('\n')
+	return nil
+}
+
+// encodeSectionList writes a node list as a section, ended by a blank line.
+func (e *encoder) encodeSectionList(name string, list []*Node) error {
+	e.WriteString("[" + name + "]\n")
+	for _, n := range list {
+		e.WriteByte('-')
+		for i, k := range n.keys {
+			if i == 0 {
+				e.WriteByte(' ')
+			} else {
+				e.WriteString("  ")
+			}
+			e.WriteString(k)
+			e.WriteString(": ")
+			if err := e.encodeVal(reflect.ValueOf(n.dict[k])); err != nil {
+				return err
+			}
+			e.WriteByte('\n')
+		}
+		if len(n.keys) == 0 {
+			e.WriteByte('\n')
+		}
+	}
+	e.WriteByte('\n')
+	return nil
+}
+
+func (e *encoder) encodeArray(v reflect.Value) error {
 	n := v.Len()
 	e.WriteByte('[')
 	for i := 0; i < n; i++ {
 		if i != 0 {
 			e.WriteByte(',')
 		}
-		e.encodeVal(v.Index(i))
+		if err := e.encodeVal(v.Index(i)); err != nil {
+			return err
+		}
 	}
 	e.WriteByte(']')
+	return nil
+}
+
+func (e *encoder) writeIndent() {
+	for i := 0; i < e.depth; i++ {
+		e.WriteByte('\t')
+	}
 }
@@ -1,6 +1,9 @@
 package rj
 
 import (
+	"bytes"
+	"errors"
+	"math"
 	"testing"
 	"time"
 )
@@ -12,45 +15,191 @@ type encodeTestCase struct {
 
 func TestEncodeVal(t *testing.T) {
 	cases := []encodeTestCase{{input: "string value", expected: `"string value"`},
+		{input: "a \"quoted\"\t\\value", expected: `"a \"quoted\"\t\\value"`},
+		{input: "a \"quoted\"\n\\value", expected: "\"\"\"-\n\ta \"quoted\"\n\t\\\\value\n\t\"\"\""},
 		{input: 123, expected: "123"},
+		{input: uint8(12), expected: "12"},
 		{input: true, expected: "true"},
 		{input: 123.456, expected: "123.456"},
+		{input: 12.0, expected: "12.0"},
+		{input: math.Inf(-1), expected: "-inf"},
+		{input: 1e21, expected: "1e+21"},
+		{input: 1e-7, expected: "1e-07"},
+		{input: 90 * time.Minute, expected: "1h30m0s"},
+		{input: uint64(math.MaxUint64), expected: "18446744073709551615"},
 		{input: time.Date(2019, 10, 11, 12, 3, 4, 0, time.UTC),
 			expected: "2019-10-11T12:03:04Z"},
+		{input: (*int)(nil), expected: "null"},
 	}
 
 	for _, tc := range cases {
-		e := newEncoder(tc.input)
-		bts := e.encode()
-		if string(bts) != tc.expected {
-			t.Error("Test doEncode failed, input:", tc.input, ", expected:", tc.expected, ", got: ", string(bts))
+		bts, err := MarshalE(tc.input)
+		if err != nil || string(bts) != tc.expected {
+			t.Error("Test encode failed, input:", tc.input, ", expected:", tc.expected, ", got: ", string(bts), err)
+		}
+	}
+}
+
+func TestEncodeFloatRoundTrip(t *testing.T) {
+	for _, f := range []float64{1e21, -1e21, 1e300, 123456789012345678901.5, 1e-300, 0.5} {
+		in := struct{ F float64 }{f}
+		out := struct{ F float64 }{}
+		bts, err := MarshalE(in)
+		if err == nil {
+			err = Unmarshal(bts, &out)
+		}
+		if err != nil || out.F != f {
+			t.Errorf("Encode float failed, expected: %v, got: %v from %q, %v", f, out.F, bts, err)
+		}
+	}
+}
+
+func TestEncodeReferenceString(t *testing.T) {
+	type price struct {
+		Price string
+		Note  string
+		X     string
+	}
+	in := price{Price: "cost ${x}", Note: "line ${x}\n$${y} and $$", X: "1"}
+	bts, err := MarshalE(in)
+	if err != nil {
+		t.Fatal("Encode failed, expected no error, got:", err)
+	}
+
+	out := price{}
+	if err = Unmarshal(bts, &out); err != nil || out != in {
+		t.Errorf("Encode string with a reference failed, expected: %+v, got: %+v from %q, %v", in, out, bts, err)
+	}
+}
+
+func TestEncodeMultilineString(t *testing.T) {
+	cases := []string{
+		"line 1\nline 2\n",
+		"line 1\n\n  indented\n\ttab",
+		"\n",
+		"ends with quotes \"\"\"\nand \\ backslash",
+		"trailing space  \n  \nlast\r\n",
+	}
+
+	for _, c := range cases {
+		in := struct{ Text string }{c}
+		bts, err := MarshalE(in)
+		if err != nil {
+			t.Error("Encode multi-line string failed, input:", c, ", got:", err)
+			continue
+		}
+
+		out := struct{ Text string }{}
+		if err = Unmarshal(bts, &out); err != nil || out.Text != c {
+			t.Errorf("Encode multi-line string failed, expected: %q, got: %q from %q, %v", c, out.Text, bts, err)
 		}
 	}
 }
 
 func TestEncodeStruct(t *testing.T) {
-	type stu struct {
+	type child struct {
 		Name string
-		Age  int
 	}
-	s := stu{Name: "Jimmy", Age: 12}
-	e := newEncoder(s)
-	bts := e.encode()
+	type stu struct {
+		Name  string
+		Age   int
+		Child child
+		Pet   *child
+		score int
+	}
+	s := stu{Name: "Jimmy", Age: 12, Child: child{"Tim"}, score: 90}
+	bts, err := MarshalE(s)
 	out := `Name: "Jimmy"
 Age: 12
+Child: {
+	Name: "Tim"
+}
+Pet: null
 `
-	if string(bts) != out {
-		t.Error("Test doEncode struct failed, expected: ", out, ", got: ", string(bts))
+	if err != nil || string(bts) != out {
+		t.Error("Test encode struct failed, expected: ", out, ", got: ", string(bts), err)
+	}
+
+	decoded := new(stu)
+	err = Unmarshal(bts, decoded)
+	if err != nil || decoded.Name != s.Name || decoded.Child.Name != s.Child.Name {
+		t.Error("Test decode encoded struct failed, expected: ", s, ", got: ", *decoded, err)
 	}
 }
 
 func TestEncodeArray(t *testing.T) {
 	in := []int{12, 23, 34}
-	e := newEncoder(in)
-	bts := e.doEncode()
+	bts, err := MarshalE(in)
 
 	out := "[12,23,34]"
-	if string(bts) != out {
-		t.Error("Test doEncode struct failed, expected: ", out, ", got: ", string(bts))
+	if err != nil || string(bts) != out {
+		t.Error("Test encode array failed, expected: ", out, ", got: ", string(bts), err)
+	}
+}
+
+func TestEncodeUnsupported(t *testing.T) {
+	type ts struct {
+		Name  string
+		Attrs map[string]string
+	}
+	cases := []interface{}{
+		map[string]int{"a": 1},
+		make(chan int),
+		func() {},
+		ts{Name: "a"},
+		[]interface{}{1, complex(1, 2)},
+	}
+
+	for _, in := range cases {
+		bts, err := MarshalE(in)
+		var typeErr *UnsupportedTypeError
+		if !errors.As(err, &typeErr) || bts != nil {
+			t.Error("Test encode unsupported type failed, input:", in, ", expected UnsupportedTypeError, got:", string(bts), err)
+		}
+	}
+}
+
+func TestEncoder_Encode(t *testing.T) {
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+
+	err := enc.Encode(map[string]int{})
+	if err == nil || buf.Len() != 0 {
+		t.Error("Encode failed, expected an error and no output, got:", buf.String(), err)
+	}
+
+	err = enc.Encode([]string{"a", "b"})
+	if err != nil || buf.String() != `["a","b"]` {
+		t.Error("Encode failed, expected: [\"a\",\"b\"], got:", buf.String(), err)
+	}
+}
+
+func TestEncodeNode(t *testing.T) {
+	in := `zeta: 1
+alpha: "a"
+
+[Mid]
+b: 1
+obj: {
+	y: 1
+	x: [1,2]
+}
+a: 2
+
+[List]
+- x: 1
+  w: 2
+- x: 3
+
+beta: true
+`
+	node, err := ParseString(in)
+	if err != nil {
+		t.Fatal("ParseString failed, expected no error, got:", err)
+	}
+
+	bts, err := MarshalE(node)
+	if err != nil || string(bts) != in {
+		t.Error("Test encode node failed, expected:", in, ", got:", string(bts), err)
 	}
 }
@@ -1,24 +1,176 @@
 package rj
 
-type RJError struct {
-	msg string
+import (
+	"strconv"
+	"strings"
+)
+
+// ErrorCode identifies the kind of a problem found while parsing.
+// The codes are stable, and an ErrorCode is itself an error,
+// so a parse result can be checked with errors.Is(err, rj.ErrInvalidEscape).
+type ErrorCode int
+
+const (
+	ErrInvalidNodeName ErrorCode = iota + 1
+	ErrInvalidName
+	ErrInvalidValue
+	ErrInvalidArray
+	ErrInvalidString
+	ErrInvalidUTF8String
+	ErrInvalidBool
+	ErrInvalidNull
+	ErrInvalidEscape
+	ErrInvalidObject
+	ErrInvalidTime
+	ErrInvalidNodeList
+	ErrUnexpectedEOF
+	ErrInvalidNumber
+	ErrNumberOutOfRange
+	ErrInvalidDuration
+	ErrInvalidByteSize
+	ErrNodeConflict
+	ErrInvalidDirective
+	ErrInvalidInclude
+	ErrIncludeCycle
+	ErrInvalidReference
+	ErrUndefinedReference
+	ErrReferenceCycle
+	ErrDuplicateKey
+	ErrDuplicateSection
+	ErrMaxDepth
+	ErrDocumentTooLarge
+	ErrStringTooLong
+	ErrArrayTooLong
+	ErrTooManyKeys
+	ErrUnknownParent
+	ErrInheritanceCycle
+	ErrInvalidAnchor
+	ErrUnknownAnchor
+	ErrIncludeNotAllowed
+	ErrEnvNotAllowed
+)
+
+var errorMessages = map[ErrorCode]string{
+	ErrInvalidNodeName:    "invalid node name",
+	ErrInvalidName:        "invalid name",
+	ErrInvalidValue:       "invalid value",
+	ErrInvalidArray:       "invalid array",
+	ErrInvalidString:      "invalid string value",
+	ErrInvalidUTF8String:  "invalid utf-8 string value",
+	ErrInvalidBool:        "invalid bool value",
+	ErrInvalidNull:        "invalid null value",
+	ErrInvalidEscape:      "invalid escape",
+	ErrInvalidObject:      "invalid object",
+	ErrInvalidTime:        "invalid time value",
+	ErrInvalidNodeList:    "invalid node list",
+	ErrUnexpectedEOF:      "unexpected end of input",
+	ErrInvalidNumber:      "invalid number",
+	ErrNumberOutOfRange:   "number out of range",
+	ErrInvalidDuration:    "invalid duration value",
+	ErrInvalidByteSize:    "invalid or ambiguous byte size unit",
+	ErrNodeConflict:       "node name conflicts with a value",
+	ErrInvalidDirective:   "invalid directive",
+	ErrInvalidInclude:     "cannot read included file",
+	ErrIncludeCycle:       "include cycle",
+	ErrInvalidReference:   "invalid reference",
+	ErrUndefinedReference: "undefined reference",
+	ErrReferenceCycle:     "reference cycle",
+	ErrDuplicateKey:       "duplicate key",
+	ErrDuplicateSection:   "duplicate section",
+	ErrMaxDepth:           "maximum nesting depth exceeded",
+	ErrDocumentTooLarge:   "document too large",
+	ErrStringTooLong:      "string too long",
+	ErrArrayTooLong:       "array too long",
+	ErrTooManyKeys:        "too many keys",
+	ErrUnknownParent:      "unknown parent section",
+	ErrInheritanceCycle:   "inheritance cycle",
+	ErrInvalidAnchor:      "invalid anchor",
+	ErrUnknownAnchor:      "unknown anchor",
+	ErrIncludeNotAllowed:  "include not allowed",
+	ErrEnvNotAllowed:      "environment reference not allowed",
 }
 
-func newError(msg string) *RJError {
-	return &RJError{msg: msg}
+func (c ErrorCode) Error() string {
+	if msg, ok := errorMessages[c]; ok {
+		return msg
+	}
+	return "unknown error " + strconv.Itoa(int(c))
 }
 
-func (e *RJError) Error() string {
-	return e.msg
+// Position is a location in an RJ document.
+type Position struct {
+	Offset int // byte offset, starting at 0
+	Line   int // line number, starting at 1
+	Column int // column number in characters, starting at 1
+}
+
+func (p Position) String() string {
+	return "line " + strconv.Itoa(p.Line) + ", column " + strconv.Itoa(p.Column)
+}
+
+// ParseError is a single problem found while parsing.
+type ParseError struct {
+	Position
+	File     string // the file the problem is in, if the input is read from a file
+	Name     string // the key or section name the problem belongs to, if any
+	Code     ErrorCode
+	Previous *Position // the earlier definition of a duplicate key or section
 }
 
-func (e *RJError) addMsg(msg string) {
-	if msg == "" {
-		return
+func newError(code ErrorCode) *ParseError {
+	return &ParseError{Code: code}
+}
+
+func (e *ParseError) Error() string {
+	var b strings.Builder
+	if e.File != "" {
+		b.WriteString(e.File)
+		b.WriteString(", ")
+	}
+	if e.Line > 0 {
+		b.WriteString(e.Position.String())
+		b.WriteString(": ")
+	}
+	b.WriteString(e.Code.Error())
+	if e.Name != "" {
+		b.WriteString(", name: ")
+		b.WriteString(e.Name)
 	}
+	if e.Previous != nil {
+		b.WriteString(", previous definition: ")
+		b.WriteString(e.Previous.String())
+	}
+	return b.String()
+}
+
+// Unwrap returns the error code, so errors.Is works on a ParseError.
+func (e *ParseError) Unwrap() error {
+	return e.Code
+}
+
+// RJError is returned by Parse and holds every problem found in the input, in document order.
+type RJError struct {
+	Errors []*ParseError
+}
 
-	if e.msg != "" {
-		e.msg += ";"
+func (e *RJError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, pe := range e.Errors {
+		msgs[i] = pe.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Is reports whether any of the errors matches target.
+func (e *RJError) Is(target error) bool {
+	for _, pe := range e.Errors {
+		if pe == target || pe.Code == target {
+			return true
+		}
 	}
-	e.msg += msg
+	return false
+}
+
+func (e *RJError) add(pe *ParseError) {
+	e.Errors = append(e.Errors, pe)
 }
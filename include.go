@@ -0,0 +1,152 @@
+package rj
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const includeDirective = "include"
+
+// setFile sets the file the scanned data comes from.
+// Errors are reported with the file name, and includes are resolved relative to it.
+func (s *scanner) setFile(path string) {
+	s.file = path
+	if abs, err := filepath.Abs(path); err == nil {
+		s.includes = []string{abs}
+	}
+}
+
+// scanDirective scans a line starting with '@', like
+//
+//	@include "common.rj"
+//	@include "conf.d/*.rj"
+//
+// An included file is scanned on its own and merged into parent at the place of the directive,
+// so the pairs after the directive override the included ones,
+// and the sections after it extend the included sections of the same name.
+// The files matched by a glob pattern are merged in lexical order, a pattern may match no file.
+// Includes are resolved in a loaded file, or if ParseOptions.AllowIncludes is set.
+func (s *scanner) scanDirective(parent *Node) {
+	start := s.offset
+	s.offset++ // skip '@'
+	nameStart := s.offset
+	s.skipUntil(func(c byte) bool { return isSpace(c) || isLineEnd(c) })
+	if string(s.data[nameStart:s.offset]) != includeDirective {
+		s.addError(newError(ErrInvalidDirective), "", start)
+		return
+	}
+
+	s.skipSpace()
+	pathStart := s.offset
+	path, err := s.scanString()
+	if err != nil {
+		s.addError(err, includeDirective, pathStart)
+		return
+	}
+
+	// only a loaded file, or input trusted by the caller, may read other files
+	if s.file == "" && !s.opts.AllowIncludes {
+		s.addError(newError(ErrIncludeNotAllowed), path, start)
+		return
+	}
+	s.include(parent, path, start)
+}
+
+// include scans the files matching path and merges them into parent.
+func (s *scanner) include(parent *Node, path string, offset int) {
+	pattern := path
+	if !filepath.IsAbs(pattern) && s.file != "" {
+		pattern = filepath.Join(filepath.Dir(s.file), pattern)
+	}
+
+	files := []string{pattern}
+	if strings.ContainsAny(path, "*?[") {
+		var err error
+		if files, err = filepath.Glob(pattern); err != nil {
+			s.addError(newError(ErrInvalidInclude), path, offset)
+			return
+		}
+		sort.Strings(files)
+	}
+
+	for _, file := range files {
+		abs, err := filepath.Abs(file)
+		if err != nil {
+			s.addError(newError(ErrInvalidInclude), path, offset)
+			continue
+		}
+		if s.isIncluding(abs) {
+			s.addError(newError(ErrIncludeCycle), path, offset)
+			continue
+		}
+
+		data, err := s.readFile(file)
+		if err != nil {
+			s.addError(newError(ErrInvalidInclude), path, offset)
+			continue
+		}
+
+		sub := newScanner(data)
+		sub.file = file
+		sub.includes = append(append([]string{}, s.includes...), abs)
+		sub.opts = s.opts
+		sub.keys, sub.aliased = s.keys, s.aliased
+		sub.scan()
+		sub.inheritSections()
+		s.keys, s.aliased = sub.keys, sub.aliased
+
+		if sub.error != nil {
+			for _, pe := range sub.error.Errors {
+				s.addError(pe, "", offset)
+			}
+		}
+		mergeNode(parent, sub.root)
+		for _, k := range sub.root.keys {
+			if n, ok := parent.dict[k].(*Node); ok {
+				s.markImplicit(n)
+			}
+		}
+	}
+}
+
+// readFile reads an included file, up to one byte over MaxDocumentSize,
+// so that the scanner of the file reports it as too large without reading all of it.
+func (s *scanner) readFile(file string) ([]byte, error) {
+	if s.opts.MaxDocumentSize == 0 {
+		return ioutil.ReadFile(file)
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ioutil.ReadAll(io.LimitReader(f, int64(s.opts.MaxDocumentSize)+1))
+}
+
+// markImplicit marks a node and its sub-nodes as extended by the sections which define them again.
+func (s *scanner) markImplicit(n *Node) {
+	if s.implicit == nil {
+		s.implicit = make(map[*Node]bool)
+	}
+	s.implicit[n] = true
+	for _, v := range n.dict {
+		if sub, ok := v.(*Node); ok {
+			s.markImplicit(sub)
+		}
+	}
+}
+
+// isIncluding reports whether the file is being scanned, directly or through includes.
+func (s *scanner) isIncluding(abs string) bool {
+	for _, f := range s.includes {
+		if f == abs {
+			return true
+		}
+	}
+	return false
+}
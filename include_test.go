@@ -0,0 +1,149 @@
+package rj
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestLoadInclude(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"main.rj": `@include "common.rj"
+name: "main"
+
+[Database]
+@include "db/defaults.rj"
+host: "db.local"
+
+[Server]
+port: 8080
+`,
+		"common.rj": `name: "common"
+level: 1
+
+[Server]
+host: "localhost"
+port: 80
+`,
+		"db/defaults.rj": `@include "conf.d/*.rj"
+pool: 10
+`,
+		"db/conf.d/b.rj": "timeout: 2s\n",
+		"db/conf.d/a.rj": "timeout: 1s\nretries: 3\n",
+	})
+
+	node, err := Load(filepath.Join(dir, "main.rj"))
+	if err != nil {
+		t.Fatal("Load with includes failed, expected no error, got:", err)
+	}
+
+	if node.GetString("name") != "main" || node.GetInt("level") != 1 {
+		t.Error("Load with includes failed, expected the included pairs to be overridden, got:", node.dict)
+	}
+	if node.GetString("Server.host") != "localhost" || node.GetInt("Server.port") != 8080 {
+		t.Error("Load with includes failed, expected the included section to be extended, got:", node.dict["Server"])
+	}
+	if node.GetString("Database.host") != "db.local" || node.GetInt("Database.pool") != 10 ||
+		node.GetInt("Database.retries") != 3 || node.GetDuration("Database.timeout").Seconds() != 2 {
+		t.Error("Load with includes failed, expected the globbed files in order, got:", node.dict["Database"])
+	}
+	if keys := node.Keys(); !arrayEquals(keys, []string{"name", "level", "Server", "Database"}) {
+		t.Error("Load with includes failed, expected keys: [name level Server Database], got:", keys)
+	}
+}
+
+func TestLoadIncludeErrors(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"main.rj":  "@include \"a.rj\"\n@include \"missing.rj\"\n@import \"a.rj\"\n",
+		"a.rj":     "x: 1\n@include \"b.rj\"\n",
+		"b.rj":     "bad: 0xZZ\n@include \"a.rj\"\n",
+		"empty.rj": "@include \"none/*.rj\"\n",
+	})
+
+	node, err := Load(filepath.Join(dir, "main.rj"))
+	var rjErr *RJError
+	if !errors.As(err, &rjErr) || len(rjErr.Errors) != 4 {
+		t.Fatal("Load with includes failed, expected four errors, got:", err)
+	}
+	if node.GetInt("x") != 1 {
+		t.Error("Load with includes failed, expected the valid pairs, got:", node.dict)
+	}
+
+	expected := []struct {
+		file string
+		line int
+		code ErrorCode
+	}{
+		{"b.rj", 1, ErrInvalidNumber},
+		{"b.rj", 2, ErrIncludeCycle},
+		{"main.rj", 2, ErrInvalidInclude},
+		{"main.rj", 3, ErrInvalidDirective},
+	}
+	for i, e := range expected {
+		pe := rjErr.Errors[i]
+		if filepath.Base(pe.File) != e.file || pe.Line != e.line || pe.Code != e.code {
+			t.Error("Load with includes failed, expected error:", e, ", got:", pe)
+		}
+	}
+
+	if _, err = Load(filepath.Join(dir, "empty.rj")); err != nil {
+		t.Error("Load with includes failed, expected no error for a glob without matches, got:", err)
+	}
+}
+
+func TestParseInclude(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"secret.rj": "password: \"s\"\n"})
+	in := []byte("@include \"" + filepath.ToSlash(filepath.Join(dir, "secret.rj")) + "\"\nname: \"main\"\n")
+
+	node, err := Parse(in)
+	var rjErr *RJError
+	if !errors.As(err, &rjErr) || len(rjErr.Errors) != 1 || rjErr.Errors[0].Code != ErrIncludeNotAllowed ||
+		rjErr.Errors[0].Line != 1 {
+		t.Error("Parse with include failed, expected error (include not allowed), got:", err)
+	}
+	if node.Has("password") || node.GetString("name") != "main" {
+		t.Error("Parse with include failed, expected the file not to be read, got:", node.dict)
+	}
+
+	if _, err = NewDecoder(bytes.NewReader(in)).DecodeNode(); !errors.Is(err, ErrIncludeNotAllowed) {
+		t.Error("Decoder with include failed, expected error (include not allowed), got:", err)
+	}
+
+	node, err = ParseWithOptions(in, ParseOptions{AllowIncludes: true})
+	if err != nil || node.GetString("password") != "s" {
+		t.Error("Parse with allowed includes failed, expected the included pairs, got:", node.dict, err)
+	}
+}
+
+func TestTokenizeDirective(t *testing.T) {
+	in := "@include \"common.rj\"\n[S]\n@include `s.rj`\n"
+	doc, err := ParseDocument([]byte(in))
+	if err != nil {
+		t.Fatal("ParseDocument failed, expected no error, got:", err)
+	}
+	if doc.String() != in || len(doc.Root().Children) == 0 || doc.Root().Children[0].Kind != SyntaxDirective {
+		t.Error("ParseDocument failed, expected a directive, got:", doc.Root().Children)
+	}
+
+	tz := NewTokenizer([]byte("@include 12\n"))
+	if _, err = tz.Next(); !errors.Is(err, ErrInvalidString) {
+		t.Error("Tokenizer failed, expected error (invalid string), got:", err)
+	}
+}
@@ -0,0 +1,114 @@
+package rj
+
+import "strings"
+
+// inheritance is a section which inherits from another one, like
+//
+//	[prod : base]
+//	host: "prod.local"
+//
+// The section gets every key of the base section, then its own pairs are applied on top.
+// Nested nodes are merged, the other values of the section replace the inherited ones.
+type inheritance struct {
+	name     string // the name of the section, for errors
+	base     string // the dotted name of the base section, from the root
+	position Position
+}
+
+// inheritor applies the inheritance of sections once the whole document is scanned,
+// so a section may inherit from a section defined after it.
+type inheritor struct {
+	root     *Node
+	sections map[*Node]inheritance
+	states   map[*Node]slotState
+	errors   []*ParseError
+}
+
+// inherit applies the inheritance of the sections under root, and returns the problems found.
+// A section whose base cannot be found, or is part of a cycle, keeps only its own pairs.
+func inherit(root *Node, sections map[*Node]inheritance) []*ParseError {
+	ih := &inheritor{root: root, sections: sections, states: make(map[*Node]slotState)}
+	ih.resolve(root)
+	return ih.errors
+}
+
+func (ih *inheritor) fail(code ErrorCode, in inheritance) {
+	ih.errors = append(ih.errors, &ParseError{Position: in.position, Name: in.name, Code: code})
+}
+
+// resolve applies the inheritance of n, then the one of its sub-nodes.
+func (ih *inheritor) resolve(n *Node) {
+	if ih.states[n] != unresolved {
+		return
+	}
+
+	ih.states[n] = resolving
+	state := resolved
+	if in, ok := ih.sections[n]; ok && !ih.apply(n, in) {
+		state = failed
+	}
+	for _, k := range n.keys {
+		if sub, ok := n.dict[k].(*Node); ok {
+			ih.resolve(sub)
+		}
+	}
+	ih.states[n] = state
+}
+
+// apply merges the pairs of n on top of a copy of its base.
+// It returns false if the base cannot be applied, which is reported once.
+func (ih *inheritor) apply(n *Node, in inheritance) bool {
+	base, ok := ih.lookup(in.base)
+	if !ok {
+		ih.fail(ErrUnknownParent, in)
+		return false
+	}
+
+	// a base which is being resolved inherits from n, or contains it
+	if ih.states[base] == resolving {
+		ih.fail(ErrInheritanceCycle, in)
+		return false
+	}
+	ih.resolve(base)
+	if ih.states[base] == failed {
+		return false
+	}
+
+	merged := copyNode(base)
+	mergeNode(merged, n)
+	n.keys, n.dict = merged.keys, merged.dict
+	return true
+}
+
+// lookup finds the node of a dotted name from the root.
+func (ih *inheritor) lookup(name string) (*Node, bool) {
+	n := ih.root
+	for _, p := range strings.Split(name, ".") {
+		next, ok := n.dict[p].(*Node)
+		if !ok {
+			return nil, false
+		}
+		n = next
+	}
+	return n, true
+}
+
+// addInheritance records that node inherits from the base section, it is applied by inheritSections.
+func (s *scanner) addInheritance(node *Node, name, base string, offset int) {
+	if s.inherits == nil {
+		s.inherits = make(map[*Node]inheritance)
+	}
+	s.inherits[node] = inheritance{name: name, base: base, position: s.position(offset)}
+}
+
+// inheritSections applies the inheritance of the sections, after the whole data is scanned.
+func (s *scanner) inheritSections() {
+	if len(s.inherits) == 0 {
+		return
+	}
+	for _, pe := range inherit(s.root, s.inherits) {
+		pe.File = s.file
+		s.addError(pe, pe.Name, 0)
+	}
+	s.inherits = nil
+}
@@ -0,0 +1,251 @@
+package rj
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const envPrefix = "env:"
+
+type slotState int
+
+const (
+	unresolved slotState = iota
+	resolving
+	resolved
+	failed // the problem is reported, the keys which refer to it fail silently
+)
+
+// slot is a key of a node.
+type slot struct {
+	node *Node
+	key  string
+}
+
+// reference is a scanned string which contains ${, resolved once the whole document is scanned.
+// It keeps where the string is, to report the problems of its references.
+type reference struct {
+	s    string
+	pos  Position
+	file string
+}
+
+// interpolator resolves the references in the strings of a document:
+//
+//	url: "http://${server.host}:${server.port}/"
+//	home: "${env:HOME}"
+//	port: "${server.port}"
+//
+// A string which is a single reference takes the value it refers to, with its type,
+// other references are replaced by their value as text. $${ is a literal ${.
+// Environment variables are only read if ParseOptions.AllowEnv is set.
+type interpolator struct {
+	root   *Node
+	opts   ParseOptions
+	states map[slot]slotState
+	errors []*ParseError
+	size   int // total length of the strings built, bounded by MaxDocumentSize
+}
+
+// interpolate resolves the references in the strings of root, and returns the problems found.
+// A value which cannot be resolved is left as it is.
+func interpolate(root *Node, opts ParseOptions) []*ParseError {
+	in := &interpolator{root: root, opts: opts, states: make(map[slot]slotState)}
+	for _, k := range root.keys {
+		in.resolveKey(root, k, k)
+	}
+	return in.errors
+}
+
+// fail reports a problem of the string r, whose path is used as the name of the error.
+func (in *interpolator) fail(code ErrorCode, path string, r reference) {
+	in.errors = append(in.errors, &ParseError{Position: r.pos, File: r.file, Name: path, Code: code})
+}
+
+// resolveKey resolves the value of a key, whose path is used in errors.
+// It returns false if the value has a problem, which is reported once.
+func (in *interpolator) resolveKey(n *Node, key, path string) (interface{}, bool) {
+	sl := slot{n, key}
+	switch in.states[sl] {
+	case resolving, failed:
+		return nil, false
+	case resolved:
+		return n.dict[key], true
+	}
+
+	in.states[sl] = resolving
+	v, ok := in.resolveValue(n.dict[key], path)
+	n.dict[key] = v
+	if ok {
+		in.states[sl] = resolved
+	} else {
+		in.states[sl] = failed
+	}
+	return v, ok
+}
+
+// resolveValue resolves the references in v. A string which cannot be resolved is kept as it is,
+// the other references of v are still resolved.
+func (in *interpolator) resolveValue(v interface{}, path string) (interface{}, bool) {
+	switch vt := v.(type) {
+	case reference:
+		return in.resolveString(vt, path)
+	case []interface{}:
+		ok := true
+		items := make([]interface{}, len(vt))
+		for i, item := range vt {
+			r, iok := in.resolveValue(item, path)
+			if !iok {
+				ok = false
+			}
+			items[i] = r
+		}
+		return typedArray(items), ok
+	case *Node:
+		ok := true
+		for _, k := range vt.keys {
+			if _, kok := in.resolveKey(vt, k, path+"."+k); !kok {
+				ok = false
+			}
+		}
+		return vt, ok
+	case []*Node:
+		ok := true
+		for i, n := range vt {
+			if _, nok := in.resolveValue(n, path+"["+strconv.Itoa(i)+"]"); !nok {
+				ok = false
+			}
+		}
+		return vt, ok
+	}
+	return v, true
+}
+
+func (in *interpolator) resolveString(r reference, path string) (interface{}, bool) {
+	s := r.s
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		if strings.HasPrefix(s[i:], "$${") {
+			b.WriteString("${")
+			i += 3
+			continue
+		}
+		if !strings.HasPrefix(s[i:], "${") {
+			b.WriteByte(s[i])
+			i++
+			continue
+		}
+
+		end := strings.IndexByte(s[i:], '}')
+		if end < 0 {
+			in.fail(ErrInvalidReference, path, r)
+			return s, false
+		}
+		end += i
+
+		v, ok := in.lookup(s[i+2:end], path, r)
+		if !ok {
+			return s, false
+		}
+		if i == 0 && end == len(s)-1 {
+			// a whole value reference keeps its type
+			return v, true
+		}
+
+		text, ok := referenceText(v)
+		if !ok {
+			in.fail(ErrInvalidReference, path, r)
+			return s, false
+		}
+		if !in.checkLength(b.Len()+len(text), path, r) {
+			return s, false
+		}
+		b.WriteString(text)
+		i = end + 1
+	}
+
+	// each string built counts, so references cannot expand the document without bound
+	in.size += b.Len()
+	if max := in.opts.MaxDocumentSize; max > 0 && in.size > max {
+		in.fail(ErrDocumentTooLarge, path, r)
+		return s, false
+	}
+	return b.String(), true
+}
+
+// checkLength reports a string of the given length which is over MaxStringLength, and returns false.
+func (in *interpolator) checkLength(length int, path string, r reference) bool {
+	if max := in.opts.MaxStringLength; max > 0 && length > max {
+		in.fail(ErrStringTooLong, path, r)
+		return false
+	}
+	return true
+}
+
+// lookup finds the value of a reference of the string r, an environment variable or a dotted path from the root.
+func (in *interpolator) lookup(ref, path string, r reference) (interface{}, bool) {
+	if strings.HasPrefix(ref, envPrefix) {
+		if !in.opts.AllowEnv {
+			in.fail(ErrEnvNotAllowed, path, r)
+			return nil, false
+		}
+		v, ok := os.LookupEnv(ref[len(envPrefix):])
+		if !ok {
+			in.fail(ErrUndefinedReference, path, r)
+			return nil, false
+		}
+		return v, in.checkLength(len(v), path, r)
+	}
+
+	names := strings.Split(ref, ".")
+	n := in.root
+	for i, name := range names {
+		if _, ok := n.dict[name]; !ok || name == "" {
+			break
+		}
+		if i == len(names)-1 {
+			if in.states[slot{n, name}] == resolving {
+				in.fail(ErrReferenceCycle, path, r)
+				return nil, false
+			}
+			return in.resolveKey(n, name, strings.Join(names, "."))
+		}
+
+		next, ok := n.dict[name].(*Node)
+		if !ok {
+			break
+		}
+		n = next
+	}
+
+	in.fail(ErrUndefinedReference, path, r)
+	return nil, false
+}
+
+// referenceText formats a value referred to inside a string.
+// Arrays, nodes and null cannot be formatted.
+func referenceText(v interface{}) (string, bool) {
+	switch vt := v.(type) {
+	case string:
+		return vt, true
+	case int:
+		return strconv.Itoa(vt), true
+	case int64:
+		return strconv.FormatInt(vt, 10), true
+	case uint64:
+		return strconv.FormatUint(vt, 10), true
+	case float64:
+		return strconv.FormatFloat(vt, 'f', -1, 64), true
+	case bool:
+		return strconv.FormatBool(vt), true
+	case time.Time:
+		return vt.Format(time.RFC3339Nano), true
+	case time.Duration:
+		return vt.String(), true
+	case ByteSize:
+		return vt.String(), true
+	}
+	return "", false
+}
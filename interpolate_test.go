@@ -0,0 +1,110 @@
+package rj
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestInterpolate(t *testing.T) {
+	os.Setenv("RJ_TEST_HOME", "/home/zoe")
+	defer os.Unsetenv("RJ_TEST_HOME")
+
+	in := `url: "http://${server.host}:${server.port}/${name}"
+port: "${server.port}"
+timeout: "${server.timeout}"
+home: "${env:RJ_TEST_HOME}/.rj"
+literal: "$${server.host}"
+hosts: ["${server.host}", "${alias}"]
+ports: ["${server.port}", 8081]
+alias: "${server.host}"
+name: "api"
+
+[server]
+host: "localhost"
+port: 8080
+timeout: 30s
+backend: "${url}backend"
+
+[servers]
+- url: "${server.host}"
+`
+	node, err := ParseWithOptions([]byte(in), ParseOptions{AllowEnv: true})
+	if err != nil {
+		t.Fatal("Interpolate failed, expected no error, got:", err)
+	}
+
+	cases := map[string]interface{}{
+		"url":            "http://localhost:8080/api",
+		"port":           8080,
+		"timeout":        30 * time.Second,
+		"home":           "/home/zoe/.rj",
+		"literal":        "${server.host}",
+		"hosts":          []string{"localhost", "localhost"},
+		"ports":          []int{8080, 8081},
+		"server.backend": "http://localhost:8080/apibackend",
+	}
+	for path, expected := range cases {
+		if v, _ := node.Get(path); !reflect.DeepEqual(v, expected) {
+			t.Errorf("Interpolate failed, path: %s, expected: %#v, got: %#v", path, expected, v)
+		}
+	}
+
+	list, _ := node.GetNodeList("servers")
+	if len(list) != 1 || list[0].GetString("url") != "localhost" {
+		t.Error("Interpolate failed, expected references in node lists to be resolved, got:", list)
+	}
+
+	node, err = ParseWithOptions([]byte(in), ParseOptions{DisableInterpolation: true})
+	if err != nil || node.GetString("port") != "${server.port}" || node.GetString("literal") != "$${server.host}" {
+		t.Error("Parse without interpolation failed, expected the strings as they are, got:", node.dict, err)
+	}
+
+	node, err = ParseString(in)
+	var rjErr *RJError
+	if !errors.As(err, &rjErr) || len(rjErr.Errors) != 1 || rjErr.Errors[0].Code != ErrEnvNotAllowed ||
+		rjErr.Errors[0].Line != 4 || node.GetString("home") != "${env:RJ_TEST_HOME}/.rj" {
+		t.Error("Parse without AllowEnv failed, expected error (environment reference not allowed), got:", err)
+	}
+}
+
+func TestInterpolateErrors(t *testing.T) {
+	in := `a: "${b}"
+b: "x${c}"
+c: "${a}"
+d: "${missing.key}"
+e: "${env:RJ_TEST_UNDEFINED}"
+f: "${list} and more"
+g: "${unterminated"
+h: "${a}"
+list: [1, 2]
+`
+	node, err := ParseWithOptions([]byte(in), ParseOptions{AllowEnv: true})
+	var rjErr *RJError
+	if !errors.As(err, &rjErr) || len(rjErr.Errors) != 5 {
+		t.Fatal("Interpolate failed, expected five errors, got:", err)
+	}
+
+	expected := []struct {
+		name string
+		line int
+		code ErrorCode
+	}{
+		{"c", 3, ErrReferenceCycle},
+		{"d", 4, ErrUndefinedReference},
+		{"e", 5, ErrUndefinedReference},
+		{"f", 6, ErrInvalidReference},
+		{"g", 7, ErrInvalidReference},
+	}
+	for i, e := range expected {
+		if pe := rjErr.Errors[i]; pe.Name != e.name || pe.Line != e.line || pe.Column != 4 || pe.Code != e.code {
+			t.Error("Interpolate failed, expected error:", e, ", got:", pe)
+		}
+	}
+
+	if node.GetString("h") != "${a}" || node.GetString("d") != "${missing.key}" {
+		t.Error("Interpolate failed, expected unresolved values to be kept, got:", node.dict)
+	}
+}
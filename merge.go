@@ -0,0 +1,182 @@
+package rj
+
+import "errors"
+
+var errNoMergeKey = errors.New("merge by key without a key")
+
+// MergeStrategy is the way Merge combines an array or node list of the other node
+// with the one of the merged node.
+type MergeStrategy int
+
+const (
+	MergeReplace MergeStrategy = iota // the other list replaces the list
+	MergeAppend                       // the items of the other list are appended to the list
+	MergeByKey                        // the nodes with the same key are merged, the other nodes are appended
+)
+
+// MergeRule is the way an array or node list is merged.
+type MergeRule struct {
+	Strategy MergeStrategy
+
+	// Key is the key which identifies the nodes of a list, with MergeByKey.
+	// A node without the key is appended. Arrays which are not node lists are appended.
+	Key string
+}
+
+// MergeOptions controls how Merge combines two nodes.
+// The zero value merges nodes deeply and lets the other values replace the merged ones.
+type MergeOptions struct {
+	// Lists is the rule of the arrays and node lists which have no rule in Rules.
+	Lists MergeRule
+
+	// Rules are the rules of the arrays and node lists at dot separated paths, like "servers" or "db.replicas".
+	// A path does not include indexes: "servers.ports" is the path of the ports of every node of servers.
+	Rules map[string]MergeRule
+
+	// UnsetNull makes a null value of the other node remove the key from the merged node,
+	// instead of setting it to null. It lets an overlay document unset a key with "key: null".
+	// The nodes added from the other node have no null values either.
+	UnsetNull bool
+}
+
+func (o *MergeOptions) rule(path string) MergeRule {
+	if r, ok := o.Rules[path]; ok {
+		return r
+	}
+	return o.Lists
+}
+
+func (o *MergeOptions) check() error {
+	if o.Lists.Strategy == MergeByKey && o.Lists.Key == "" {
+		return errNoMergeKey
+	}
+	for _, r := range o.Rules {
+		if r.Strategy == MergeByKey && r.Key == "" {
+			return errNoMergeKey
+		}
+	}
+	return nil
+}
+
+// Merge merges other into the node, to layer overrides on top of defaults.
+// The nodes which are in both are merged deeply, the arrays and node lists are combined
+// according to the options, and the other values of other replace those of the node.
+// The values taken from other are copied, so changing other later does not change the node.
+func (n *Node) Merge(other *Node, opts MergeOptions) error {
+	if err := opts.check(); err != nil {
+		return err
+	}
+	if other != nil && other != n {
+		opts.merge(n, other, "")
+	}
+	return nil
+}
+
+func (o *MergeOptions) merge(dst, src *Node, path string) {
+	for _, k := range src.keys {
+		p := k
+		if path != "" {
+			p = path + "." + k
+		}
+
+		sv := src.dict[k]
+		if sv == nil && o.UnsetNull {
+			dst.delete(k)
+			continue
+		}
+		if dv, ok := dst.dict[k]; ok {
+			dst.set(k, o.mergeValue(dv, sv, p))
+		} else {
+			dst.set(k, o.copy(sv))
+		}
+	}
+}
+
+func (o *MergeOptions) mergeValue(dv, sv interface{}, path string) interface{} {
+	switch svt := sv.(type) {
+	case *Node:
+		if dn, ok := dv.(*Node); ok {
+			if dn != svt {
+				o.merge(dn, svt, path)
+			}
+			return dn
+		}
+	case []*Node:
+		if dl, ok := nodeList(dv); ok {
+			return o.mergeList(dl, svt, path)
+		}
+	case []interface{}:
+		if dl, ok := dv.([]*Node); ok && len(svt) == 0 {
+			return o.mergeList(dl, []*Node{}, path)
+		}
+	}
+
+	if kindOf(sv) == KindArray && kindOf(dv) == KindArray && o.rule(path).Strategy != MergeReplace {
+		items := append(append([]interface{}{}, itemsOf(dv)...), itemsOf(o.copy(sv))...)
+		return typedArray(items)
+	}
+	return o.copy(sv)
+}
+
+func (o *MergeOptions) mergeList(dl, sl []*Node, path string) []*Node {
+	rule := o.rule(path)
+	switch rule.Strategy {
+	case MergeAppend:
+		return append(dl[:len(dl):len(dl)], o.copy(sl).([]*Node)...)
+	case MergeByKey:
+		list := dl[:len(dl):len(dl)]
+		for _, sn := range sl {
+			if dn := findByKey(list, rule.Key, sn); dn != nil {
+				o.merge(dn, sn, path)
+			} else {
+				list = append(list, o.copy(sn).(*Node))
+			}
+		}
+		return list
+	}
+	return o.copy(sl).([]*Node)
+}
+
+// copy copies a value taken from the other node. With UnsetNull, the null values of its nodes are removed,
+// as they unset keys which are not in the merged node.
+func (o *MergeOptions) copy(v interface{}) interface{} {
+	c := copyValue(v)
+	if o.UnsetNull {
+		removeNulls(c)
+	}
+	return c
+}
+
+// removeNulls removes the keys whose value is null from the nodes of a value.
+func removeNulls(v interface{}) {
+	if n, ok := v.(*Node); ok {
+		for _, k := range append([]string{}, n.keys...) {
+			if n.dict[k] == nil {
+				n.delete(k)
+			} else {
+				removeNulls(n.dict[k])
+			}
+		}
+		return
+	}
+	switch v.(type) {
+	case []*Node, []interface{}:
+		for _, item := range itemsOf(v) {
+			removeNulls(item)
+		}
+	}
+}
+
+// findByKey finds the node of a list whose key has the same value as the key of n.
+func findByKey(list []*Node, key string, n *Node) *Node {
+	v, ok := n.dict[key]
+	if !ok {
+		return nil
+	}
+	for _, item := range list {
+		if iv, ok := item.dict[key]; ok && compare(iv, v, "==") {
+			return item
+		}
+	}
+	return nil
+}
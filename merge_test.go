@@ -0,0 +1,127 @@
+package rj
+
+import (
+	"reflect"
+	"testing"
+)
+
+const mergeBase = `name: "api"
+debug: true
+ports: [80, 443]
+tags: ["a"]
+db: {host: "localhost"
+	port: 5432}
+
+[servers]
+- name: "a"
+  port: 80
+- name: "b"
+  port: 81
+`
+
+const mergeOverlay = `debug: null
+ports: [8080]
+tags: ["b"]
+db: {host: "db.prod"}
+level: 2
+
+[servers]
+- name: "b"
+  port: 8081
+- name: "c"
+  port: 82
+`
+
+func TestNode_Merge(t *testing.T) {
+	overlay, _ := ParseString(mergeOverlay)
+
+	node, _ := ParseString(mergeBase)
+	if err := node.Merge(overlay, MergeOptions{}); err != nil {
+		t.Fatal("Merge failed, expected no error, got:", err)
+	}
+	list, _ := node.GetNodeList("servers")
+	if node.GetString("db.host") != "db.prod" || node.GetInt("db.port") != 5432 || node.GetInt("level") != 2 ||
+		!reflect.DeepEqual(node.GetIntArray("ports"), []int{8080}) || len(list) != 2 || list[0].GetString("name") != "b" {
+		t.Error("Merge failed, expected nodes merged and lists replaced, got:", node.dict)
+	}
+	if v, err := node.Get("debug"); err != nil || v != nil {
+		t.Error("Merge failed, expected null to be set, got:", v, err)
+	}
+	if keys := node.Keys(); !arrayEquals(keys, []string{"name", "debug", "ports", "tags", "db", "servers", "level"}) {
+		t.Error("Merge failed, expected keys in order, got:", keys)
+	}
+
+	node, _ = ParseString(mergeBase)
+	err := node.Merge(overlay, MergeOptions{
+		Lists:     MergeRule{Strategy: MergeAppend},
+		Rules:     map[string]MergeRule{"servers": {Strategy: MergeByKey, Key: "name"}, "tags": {}},
+		UnsetNull: true,
+	})
+	if err != nil {
+		t.Fatal("Merge failed, expected no error, got:", err)
+	}
+	if node.Has("debug") || !reflect.DeepEqual(node.GetIntArray("ports"), []int{80, 443, 8080}) ||
+		!arrayEquals(node.GetStringArray("tags"), []string{"b"}) {
+		t.Error("Merge failed, expected arrays appended and debug unset, got:", node.dict)
+	}
+	if ports := node.GetIntArray("servers[*].port"); !reflect.DeepEqual(ports, []int{80, 8081, 82}) {
+		t.Error("Merge failed, expected servers merged by name, got:", ports)
+	}
+
+	list, _ = overlay.GetNodeList("servers")
+	list[1].Set("port", 1)
+	if node.GetInt("servers[2].port") != 82 {
+		t.Error("Merge failed, expected the merged values to be copied")
+	}
+
+	if err = node.Merge(overlay, MergeOptions{Rules: map[string]MergeRule{"x": {Strategy: MergeByKey}}}); err != errNoMergeKey {
+		t.Error("Merge failed, expected error (no merge key), got:", err)
+	}
+}
+
+func TestNode_MergeEmptyArray(t *testing.T) {
+	node, _ := ParseString(mergeBase)
+	overlay, _ := ParseString("servers: []\n")
+	if err := node.Merge(overlay, MergeOptions{Lists: MergeRule{Strategy: MergeAppend}}); err != nil {
+		t.Fatal("Merge failed, expected no error, got:", err)
+	}
+	if list, err := node.GetNodeList("servers"); err != nil || len(list) != 2 {
+		t.Error("Merge failed, expected the node list to be kept, got:", list, err)
+	}
+
+	node, _ = ParseString("servers: []\n")
+	overlay, _ = ParseString(mergeBase)
+	if err := node.Merge(overlay, MergeOptions{Lists: MergeRule{Strategy: MergeByKey, Key: "name"}}); err != nil {
+		t.Fatal("Merge failed, expected no error, got:", err)
+	}
+	if list, err := node.GetNodeList("servers"); err != nil || len(list) != 2 {
+		t.Error("Merge failed, expected the nodes merged into the empty array, got:", list, err)
+	}
+}
+
+func TestNode_MergeUnsetNull(t *testing.T) {
+	node, _ := ParseString("name: \"api\"\nports: [{port: 80}]\n")
+	overlay, _ := ParseString(`db: {password: null
+	host: "h"
+	pool: {size: null}}
+ports: [{tls: null
+	port: 443}]
+
+[servers]
+- name: "a"
+  proxy: null
+`)
+	err := node.Merge(overlay, MergeOptions{Lists: MergeRule{Strategy: MergeAppend}, UnsetNull: true})
+	if err != nil {
+		t.Fatal("Merge failed, expected no error, got:", err)
+	}
+	if node.Has("db.password") || node.Has("db.pool.size") || node.GetString("db.host") != "h" {
+		t.Error("Merge failed, expected the nulls of a new node to be removed, got:", node.dict["db"])
+	}
+	if node.Has("servers[0].proxy") || node.Has("ports[1].tls") || node.GetInt("ports[1].port") != 443 {
+		t.Error("Merge failed, expected the nulls of new list items to be removed, got:", node.dict)
+	}
+	if !overlay.Has("db.password") {
+		t.Error("Merge failed, expected the other node not to be changed")
+	}
+}
@@ -2,6 +2,7 @@ package rj
 
 import (
 	"errors"
+	"math"
 	"reflect"
 	"strings"
 	"time"
@@ -12,11 +13,16 @@ var (
 	errValueNotAssignable = errors.New("v is not assignable")
 	errTypeMismatch       = errors.New("type mismatch")
 	errNoName             = errors.New("no name provided")
+	errNilNode            = errors.New("nil node")
+	errNodeCycle          = errors.New("a node cannot contain itself")
+	errInvalidName        = errors.New("invalid name")
 )
 
 // Node is the represent of a RJ Doc.
+// It remembers the order in which its keys, sections and node lists are defined.
 type Node struct {
 	dict map[string]interface{}
+	keys []string
 }
 
 // NewNode creates an empty RJ Node
@@ -24,9 +30,133 @@ func NewNode() *Node {
 	return &Node{dict: make(map[string]interface{})}
 }
 
+// Keys returns the keys of the node in document order.
+// A key which is defined again keeps its first position.
+func (n *Node) Keys() []string {
+	return append([]string{}, n.keys...)
+}
+
+// Len returns the number of keys of the node.
+func (n *Node) Len() int {
+	return len(n.keys)
+}
+
+// Has reports whether there is a value at the dot separated path, which may be null.
+func (n *Node) Has(path string) bool {
+	_, err := n.Get(path)
+	return err == nil
+}
+
+// Kind returns the kind of the value at the dot separated path.
+// It returns KindInvalid if there is no such value.
+func (n *Node) Kind(path string) Kind {
+	v, err := n.Get(path)
+	if err != nil {
+		return KindInvalid
+	}
+	return kindOf(v)
+}
+
+// Range calls fn for each key of the node and its value, in the order of Keys, until fn returns false.
+// The keys which fn adds to the node are not visited, the ones it deletes are not visited anymore.
+func (n *Node) Range(fn func(key string, v Value) bool) {
+	for _, k := range n.Keys() {
+		v, ok := n.dict[k]
+		if !ok {
+			continue
+		}
+		if !fn(k, Value{v}) {
+			return
+		}
+	}
+}
+
+// set sets the value of a key, keeping the order of keys.
+func (n *Node) set(name string, val interface{}) {
+	if _, ok := n.dict[name]; !ok {
+		n.keys = append(n.keys, name)
+	}
+	n.dict[name] = val
+}
+
+// delete removes a key and its value.
+func (n *Node) delete(name string) {
+	delete(n.dict, name)
+	for i, k := range n.keys {
+		if k == name {
+			n.keys = append(n.keys[:i:i], n.keys[i+1:]...)
+			return
+		}
+	}
+}
+
+// mergeNode merges the pairs of src into dst. Nodes which are in both are merged deeply,
+// other values of src replace those of dst.
+func mergeNode(dst, src *Node) {
+	for _, k := range src.keys {
+		sv := src.dict[k]
+		if sn, ok := sv.(*Node); ok {
+			if dn, ok := dst.dict[k].(*Node); ok && dn != sn {
+				mergeNode(dn, sn)
+				continue
+			}
+		}
+		dst.set(k, sv)
+	}
+}
+
+// copyNode copies a node and the nodes in it, so that changing the copy does not change n.
+func copyNode(n *Node) *Node {
+	c := NewNode()
+	for _, k := range n.keys {
+		c.set(k, copyValue(n.dict[k]))
+	}
+	return c
+}
+
+// copyValue copies the nodes of a value. Other values are immutable, they are not copied.
+func copyValue(v interface{}) interface{} {
+	switch vt := v.(type) {
+	case *Node:
+		return copyNode(vt)
+	case []*Node:
+		list := make([]*Node, len(vt))
+		for i, n := range vt {
+			list[i] = copyNode(n)
+		}
+		return list
+	case []interface{}:
+		items := make([]interface{}, len(vt))
+		for i, item := range vt {
+			items[i] = copyValue(item)
+		}
+		return items
+	}
+	return v
+}
+
 // Get gets the value of the input name.
+// The name is a dot separated path, or a query like "servers[0].host", see Query.
+// A query which may match several values, like "servers[*].port", gets a slice of the values.
+// An invalid query is an error, unless it is the name of a key, like "a[0]".
 // It will return an error if there is anything wrong.
 func (n *Node) Get(name string) (val interface{}, err error) {
+	if strings.IndexByte(name, '[') < 0 {
+		return n.getKey(name)
+	}
+
+	if val, err = n.query(name); err == nil {
+		return
+	}
+	// a key may have brackets in its name, otherwise the query error is returned
+	if v, kerr := n.getKey(name); kerr == nil {
+		return v, nil
+	}
+	return nil, err
+}
+
+// getKey gets the value of a dot separated path.
+func (n *Node) getKey(name string) (val interface{}, err error) {
 	finalName, finalNode, err := getFinalNameAndNode(name, n)
 
 	if err != nil {
@@ -45,6 +175,204 @@ func (n *Node) Get(name string) (val interface{}, err error) {
 	return
 }
 
+// Set sets the value at the dot separated path, like "server.tls.port".
+// Missing nodes on the path are created, a value on the path which is not a node is an error.
+// The value must be of a type RJ can represent: a string, bool, number, time.Time, time.Duration,
+// ByteSize, *Node, []*Node, nil, or a slice or array of them. Other integer and float types are converted.
+func (n *Node) Set(path string, val interface{}) error {
+	v, err := normalizeValue(reflect.ValueOf(val))
+	if err != nil {
+		return err
+	}
+
+	name, parent, err := makePath(path, n)
+	if err != nil {
+		return err
+	}
+	if containsNode(v, parent) {
+		return errNodeCycle
+	}
+
+	parent.set(name, v)
+	return nil
+}
+
+// SetNode sets a node at the dot separated path, like Set.
+func (n *Node) SetNode(path string, node *Node) error {
+	if node == nil {
+		return errNilNode
+	}
+	return n.Set(path, node)
+}
+
+// Delete removes the value at the dot separated path.
+// It returns an error if there is no such value.
+func (n *Node) Delete(path string) error {
+	name, parent, err := getFinalNameAndNode(path, n)
+	if err != nil {
+		return err
+	}
+	if _, ok := parent.dict[name]; !ok {
+		return errValueNotFound
+	}
+
+	parent.delete(name)
+	return nil
+}
+
+// AppendToList appends a node to the node list at the dot separated path.
+// The list, and missing nodes on the path, are created if they are missing.
+func (n *Node) AppendToList(path string, node *Node) error {
+	if node == nil {
+		return errNilNode
+	}
+
+	name, parent, err := makePath(path, n)
+	if err != nil {
+		return err
+	}
+	if containsNode(node, parent) {
+		return errNodeCycle
+	}
+
+	var list []*Node
+	if v, ok := parent.dict[name]; ok {
+		if list, ok = v.([]*Node); !ok {
+			return errTypeMismatch
+		}
+	}
+	// the list may share its array with a copy, so it is not appended in place
+	parent.set(name, append(list[:len(list):len(list)], node))
+	return nil
+}
+
+// makePath walks a dot separated path like getFinalNameAndNode, and creates the missing nodes on the way.
+func makePath(path string, node *Node) (name string, parent *Node, err error) {
+	names := strings.Split(path, ".")
+	for _, nm := range names {
+		if nm == "" {
+			return "", nil, errNoName
+		}
+		if !isKeyName(nm) {
+			return "", nil, errInvalidName
+		}
+	}
+
+	for _, nm := range names[:len(names)-1] {
+		v, ok := node.dict[nm]
+		switch vt := v.(type) {
+		case *Node:
+			node = vt
+			continue
+		case nil:
+			if !ok {
+				child := NewNode()
+				node.set(nm, child)
+				node = child
+				continue
+			}
+		}
+		return "", nil, errTypeMismatch
+	}
+	return names[len(names)-1], node, nil
+}
+
+// normalizeValue converts a value to the type the scanner would give it.
+// Slices and arrays are converted like the arrays of a document.
+func normalizeValue(v reflect.Value) (interface{}, error) {
+	switch v.Kind() {
+	case reflect.Invalid:
+		return nil, nil
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Bool:
+		return v.Bool(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		switch v.Type() {
+		case durationType:
+			return time.Duration(v.Int()), nil
+		case byteSizeType:
+			return ByteSize(v.Int()), nil
+		}
+		// like decodeNumber, ints which do not fit in int stay int64
+		if i := v.Int(); int64(int(i)) != i {
+			return i, nil
+		}
+		return int(v.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if u := v.Uint(); u > math.MaxInt64 {
+			return u, nil
+		}
+		return normalizeValue(reflect.ValueOf(int64(v.Uint())))
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), nil
+	case reflect.Struct:
+		if v.Type() == timeType {
+			return v.Interface().(time.Time), nil
+		}
+	case reflect.Ptr:
+		if v.Type() == nodeType {
+			if v.IsNil() {
+				return nil, nil
+			}
+			return v.Interface().(*Node), nil
+		}
+	case reflect.Interface:
+		return normalizeValue(v.Elem())
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return nil, nil
+		}
+		if list, ok := v.Interface().([]*Node); ok {
+			for _, item := range list {
+				if item == nil {
+					return nil, errNilNode
+				}
+			}
+			return list, nil
+		}
+
+		items := make([]interface{}, v.Len())
+		for i := range items {
+			item, err := normalizeValue(v.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			items[i] = item
+		}
+		return typedArray(items), nil
+	}
+	return nil, &UnsupportedTypeError{v.Type()}
+}
+
+// containsNode reports whether parent is in the nodes of v, which would make it contain itself.
+func containsNode(v interface{}, parent *Node) bool {
+	switch vt := v.(type) {
+	case *Node:
+		if vt == parent {
+			return true
+		}
+		for _, item := range vt.dict {
+			if containsNode(item, parent) {
+				return true
+			}
+		}
+	case []*Node:
+		for _, n := range vt {
+			if containsNode(n, parent) {
+				return true
+			}
+		}
+	case []interface{}:
+		for _, item := range vt {
+			if containsNode(item, parent) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 // GetString gets a string value of the input name.
 // It will return an empty string if there is any error.
 func (n *Node) GetString(name string) string {
@@ -121,6 +449,84 @@ func (n *Node) GetIntOrError(name string) (val int, err error) {
 	}
 }
 
+// GetInt64 gets an int64 value of the input name.
+// It will return 0 if there is any error.
+func (n *Node) GetInt64(name string) int64 {
+	return n.GetInt64Or(name, 0)
+}
+
+// GetInt64Or gets an int64 value of the input name.
+// It will return the input default value if there is any error.
+func (n *Node) GetInt64Or(name string, defaultVal int64) int64 {
+	val, err := n.GetInt64OrError(name)
+	if err != nil {
+		return defaultVal
+	}
+	return val
+}
+
+// GetInt64OrError gets an int64 value from the node.
+// Any integer in the range of int64 is accepted.
+func (n *Node) GetInt64OrError(name string) (val int64, err error) {
+	v, err := n.Get(name)
+
+	if err != nil {
+		return
+	}
+
+	switch val := v.(type) {
+	case int:
+		return int64(val), nil
+	case int64:
+		return val, nil
+	case uint64:
+		if val <= math.MaxInt64 {
+			return int64(val), nil
+		}
+	}
+	return 0, errTypeMismatch
+}
+
+// GetUint64 gets an uint64 value of the input name.
+// It will return 0 if there is any error.
+func (n *Node) GetUint64(name string) uint64 {
+	return n.GetUint64Or(name, 0)
+}
+
+// GetUint64Or gets an uint64 value of the input name.
+// It will return the input default value if there is any error.
+func (n *Node) GetUint64Or(name string, defaultVal uint64) uint64 {
+	val, err := n.GetUint64OrError(name)
+	if err != nil {
+		return defaultVal
+	}
+	return val
+}
+
+// GetUint64OrError gets an uint64 value from the node.
+// Any integer which is not negative is accepted.
+func (n *Node) GetUint64OrError(name string) (val uint64, err error) {
+	v, err := n.Get(name)
+
+	if err != nil {
+		return
+	}
+
+	switch val := v.(type) {
+	case int:
+		if val >= 0 {
+			return uint64(val), nil
+		}
+	case int64:
+		if val >= 0 {
+			return uint64(val), nil
+		}
+	case uint64:
+		return val, nil
+	}
+	return 0, errTypeMismatch
+}
+
 // GetFloat gets a string value of the input name.
 // It will return 0 if there is any error.
 func (n *Node) GetFloat(name string) float64 {
@@ -235,8 +641,84 @@ func (n *Node) GetTimeOrError(name string) (val time.Time, err error) {
 	}
 }
 
+// GetDuration gets a duration value of the input name.
+// It will return 0 if there is any error.
+func (n *Node) GetDuration(name string) time.Duration {
+	return n.GetDurationOr(name, 0)
+}
+
+// GetDurationOr gets a duration value of the input name.
+// It will return the input default value if there is any error.
+func (n *Node) GetDurationOr(name string, defaultVal time.Duration) time.Duration {
+	val, err := n.Get(name)
+
+	if err == nil {
+		switch v := val.(type) {
+		case time.Duration:
+			return v
+		}
+	}
+
+	return defaultVal
+}
+
+// GetDurationOrError gets a duration value from the node.
+// It will return the error directly if there is one.
+func (n *Node) GetDurationOrError(name string) (val time.Duration, err error) {
+	v, err := n.Get(name)
+
+	if err != nil {
+		return
+	}
+
+	switch val := v.(type) {
+	case time.Duration:
+		return val, nil
+	default:
+		return 0, errTypeMismatch
+	}
+}
+
+// GetBytesSize gets a byte size value of the input name.
+// It will return 0 if there is any error.
+func (n *Node) GetBytesSize(name string) ByteSize {
+	return n.GetBytesSizeOr(name, 0)
+}
+
+// GetBytesSizeOr gets a byte size value of the input name.
+// It will return the input default value if there is any error.
+func (n *Node) GetBytesSizeOr(name string, defaultVal ByteSize) ByteSize {
+	val, err := n.GetBytesSizeOrError(name)
+	if err != nil {
+		return defaultVal
+	}
+	return val
+}
+
+// GetBytesSizeOrError gets a byte size value from the node.
+// A plain integer is taken as a number of bytes.
+func (n *Node) GetBytesSizeOrError(name string) (val ByteSize, err error) {
+	v, err := n.Get(name)
+
+	if err != nil {
+		return
+	}
+
+	switch val := v.(type) {
+	case ByteSize:
+		return val, nil
+	case int:
+		return ByteSize(val), nil
+	case int64:
+		return ByteSize(val), nil
+	default:
+		return 0, errTypeMismatch
+	}
+}
+
 // GetArrayOrError gets an array from the node.
 // It will return error if there is any.
+// The empty array [] is a []interface{}, the typed array getters and GetNodeList get it as an empty slice.
 func (n *Node) GetArrayOrError(name string) (array interface{}, err error) {
 	val, err := n.Get(name)
 	switch arr := val.(type) {
@@ -248,8 +730,20 @@ func (n *Node) GetArrayOrError(name string) (array interface{}, err error) {
 		array = arr
 	case []float64:
 		array = arr
+	case []int64:
+		array = arr
+	case []uint64:
+		array = arr
 	case []time.Time:
 		array = arr
+	case []time.Duration:
+		array = arr
+	case []ByteSize:
+		array = arr
+	case []*Node:
+		array = arr
+	case []interface{}:
+		array = arr
 	case nil:
 		err = errValueNotFound
 	default:
@@ -268,9 +762,12 @@ func (n *Node) GetStringArrayOrError(name string) (arr []string, err error) {
 	switch arr := val.(type) {
 	case []string:
 		return arr, nil
-	default:
-		return nil, errTypeMismatch
+	case []interface{}:
+		if len(arr) == 0 {
+			return []string{}, nil
+		}
 	}
+	return nil, errTypeMismatch
 }
 
 // GetStringArray gets an array of string from the node.
@@ -281,6 +778,10 @@ func (n *Node) GetStringArray(name string) []string {
 		switch arr := val.(type) {
 		case []string:
 			return arr
+		case []interface{}:
+			if len(arr) == 0 {
+				return []string{}
+			}
 		}
 	}
 
@@ -297,9 +798,12 @@ func (n *Node) GetBoolArrayOrError(name string) (arr []bool, err error) {
 	switch arr := val.(type) {
 	case []bool:
 		return arr, nil
-	default:
-		return nil, errTypeMismatch
+	case []interface{}:
+		if len(arr) == 0 {
+			return []bool{}, nil
+		}
 	}
+	return nil, errTypeMismatch
 }
 
 // GetBoolArray gets an array of bool from the node.
@@ -310,6 +814,10 @@ func (n *Node) GetBoolArray(name string) []bool {
 		switch arr := val.(type) {
 		case []bool:
 			return arr
+		case []interface{}:
+			if len(arr) == 0 {
+				return []bool{}
+			}
 		}
 	}
 
@@ -326,9 +834,12 @@ func (n *Node) GetIntArrayOrError(name string) (arr []int, err error) {
 	switch arr := val.(type) {
 	case []int:
 		return arr, nil
-	default:
-		return nil, errTypeMismatch
+	case []interface{}:
+		if len(arr) == 0 {
+			return []int{}, nil
+		}
 	}
+	return nil, errTypeMismatch
 }
 
 // GetIntArray gets an array of int from the node.
@@ -339,6 +850,10 @@ func (n *Node) GetIntArray(name string) []int {
 		switch arr := val.(type) {
 		case []int:
 			return arr
+		case []interface{}:
+			if len(arr) == 0 {
+				return []int{}
+			}
 		}
 	}
 
@@ -355,9 +870,12 @@ func (n *Node) GetFloatArrayOrError(name string) (arr []float64, err error) {
 	switch arr := val.(type) {
 	case []float64:
 		return arr, nil
-	default:
-		return nil, errTypeMismatch
+	case []interface{}:
+		if len(arr) == 0 {
+			return []float64{}, nil
+		}
 	}
+	return nil, errTypeMismatch
 }
 
 // GetFloatArray gets an array of float from the node.
@@ -368,6 +886,10 @@ func (n *Node) GetFloatArray(name string) []float64 {
 		switch arr := val.(type) {
 		case []float64:
 			return arr
+		case []interface{}:
+			if len(arr) == 0 {
+				return []float64{}
+			}
 		}
 	}
 
@@ -384,9 +906,12 @@ func (n *Node) GetTimeArrayOrError(name string) (arr []time.Time, err error) {
 	switch arr := val.(type) {
 	case []time.Time:
 		return arr, nil
-	default:
-		return nil, errTypeMismatch
+	case []interface{}:
+		if len(arr) == 0 {
+			return []time.Time{}, nil
+		}
 	}
+	return nil, errTypeMismatch
 }
 
 // GetDatetimeArray gets an array of time.Time from the node.
@@ -397,6 +922,10 @@ func (n *Node) GetTimeArray(name string) []time.Time {
 		switch arr := val.(type) {
 		case []time.Time:
 			return arr
+		case []interface{}:
+			if len(arr) == 0 {
+				return []time.Time{}
+			}
 		}
 	}
 
@@ -428,32 +957,32 @@ func (n *Node) GetNode(name string) (node *Node, err error) {
 	return nil, errTypeMismatch
 }
 
-// GetStructList get a list of struct from the node
+// GetStructList get a list of struct from the node.
+// v must be a pointer to a slice of struct or pointer to struct.
 func (n *Node) GetStructList(name string, v interface{}) (err error) {
 	rv := reflect.ValueOf(v)
-	switch rv.Kind() {
-	case reflect.Array:
-		println("interface")
-	case reflect.Slice:
-		println("interface")
-	case reflect.Interface:
-		println("interface")
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Slice {
+		return errValueNotAssignable
 	}
+
 	list, err := n.GetNodeList(name)
-	if err == nil {
+	if err != nil {
 		return
 	}
 
 	l := len(list)
-	vt := reflect.TypeOf(v)
+	vt := rv.Elem().Type()
 	array := reflect.MakeSlice(vt, l, l)
 
 	for i := 0; i < l; i++ {
-		el := decodeStructField(list[i], vt.Elem())
+		el, err := decodeStructField(list[i], vt.Elem())
+		if err != nil {
+			return err
+		}
 		array.Index(i).Set(el)
 	}
 
-	v = array.Interface()
+	rv.Elem().Set(array)
 
 	return
 }
@@ -465,14 +994,25 @@ func (n *Node) GetNodeList(name string) (list []*Node, err error) {
 		return
 	}
 
-	switch vt := val.(type) {
-	case []*Node:
-		return vt, nil
+	if list, ok := nodeList(val); ok {
+		return list, nil
 	}
-
 	return nil, errTypeMismatch
 }
 
+// nodeList returns a value as a node list. The empty array [] has no item type, it is an empty node list.
+func nodeList(v interface{}) ([]*Node, bool) {
+	switch vt := v.(type) {
+	case []*Node:
+		return vt, true
+	case []interface{}:
+		if len(vt) == 0 {
+			return []*Node{}, true
+		}
+	}
+	return nil, false
+}
+
 // ToStruct decode the node itself to a struct
 func (n *Node) ToStruct(val interface{}) error {
 	return decode(n, val)
@@ -1,6 +1,8 @@
 package rj
 
 import (
+	"math"
+	"reflect"
 	"testing"
 	"time"
 )
@@ -119,6 +121,52 @@ func TestGetInt(t *testing.T) {
 	}
 }
 
+func TestGetInt64(t *testing.T) {
+	node, err := ParseString("small: 12\nbig: 18446744073709551615\nneg: -3\nname: \"Zoe\"\n")
+	if err != nil {
+		t.Fatal("Parse failed, expected no error, got:", err)
+	}
+
+	if v, err := node.GetInt64OrError("small"); err != nil || v != 12 {
+		t.Error("GetInt64OrError failed, expected: 12, got:", v, err)
+	}
+	if _, err := node.GetInt64OrError("big"); err != errTypeMismatch {
+		t.Error("GetInt64OrError failed, expected error (type mismatch), got:", err)
+	}
+	if v := node.GetInt64Or("name", 7); v != 7 {
+		t.Error("GetInt64Or failed, expected: 7, got:", v)
+	}
+
+	if v := node.GetUint64("big"); v != math.MaxUint64 {
+		t.Error("GetUint64 failed, expected:", uint64(math.MaxUint64), ", got:", v)
+	}
+	if _, err := node.GetUint64OrError("neg"); err != errTypeMismatch {
+		t.Error("GetUint64OrError failed, expected error (type mismatch), got:", err)
+	}
+	if v := node.GetUint64("small"); v != 12 {
+		t.Error("GetUint64 failed, expected: 12, got:", v)
+	}
+}
+
+func TestGetDuration(t *testing.T) {
+	node := NewNode()
+	node.dict["timeout"] = 30 * time.Second
+	node.dict["retries"] = 3
+
+	if d, err := node.GetDurationOrError("timeout"); err != nil || d != 30*time.Second {
+		t.Error("GetDurationOrError failed, expected: 30s, got:", d, err)
+	}
+	if _, err := node.GetDurationOrError("retries"); err != errTypeMismatch {
+		t.Error("GetDurationOrError failed, expected error (type mismatch), got:", err)
+	}
+	if d := node.GetDurationOr("retries", time.Minute); d != time.Minute {
+		t.Error("GetDurationOr failed, expected: 1m0s, got:", d)
+	}
+	if d := node.GetDuration("missing"); d != 0 {
+		t.Error("GetDuration failed, expected: 0s, got:", d)
+	}
+}
+
 func TestGetBool(t *testing.T) {
 	node := NewNode()
 	node.dict["name"] = "Zoe"
@@ -280,3 +328,212 @@ func TestNode_GetStringArray(t *testing.T) {
 	}
 
 }
+
+func TestNode_GetEmptyArray(t *testing.T) {
+	node, _ := ParseString("a: []\nb: [1]\n")
+
+	if arr, err := node.GetIntArrayOrError("a"); err != nil || arr == nil || len(arr) != 0 {
+		t.Error("GetIntArrayOrError failed, expected an empty array, got:", arr, err)
+	}
+	if arr := node.GetStringArray("a"); arr == nil || len(arr) != 0 {
+		t.Error("GetStringArray failed, expected an empty array, got:", arr)
+	}
+	if list, err := node.GetNodeList("a"); err != nil || list == nil || len(list) != 0 {
+		t.Error("GetNodeList failed, expected an empty list, got:", list, err)
+	}
+	if _, err := node.GetStringArrayOrError("b"); err != errTypeMismatch {
+		t.Error("GetStringArrayOrError failed, expected error (type mismatch), got:", err)
+	}
+}
+
+func TestNode_GetStructList(t *testing.T) {
+	type server struct {
+		Host string
+	}
+
+	a := NewNode()
+	a.dict["Host"] = "a"
+	b := NewNode()
+	b.dict["Host"] = "b"
+	node := NewNode()
+	node.dict["Servers"] = []*Node{a, b}
+
+	var list []server
+	err := node.GetStructList("Servers", &list)
+	if err != nil {
+		t.Error("GetStructList failed, expected no error, got:", err)
+	} else if len(list) != 2 || list[0].Host != "a" || list[1].Host != "b" {
+		t.Error("GetStructList failed, expected: [{a} {b}], got:", list)
+	}
+
+	err = node.GetStructList("Servers", list)
+	if err != errValueNotAssignable {
+		t.Error("GetStructList failed, should return error (v is not assignable), got:", err)
+	}
+}
+
+func TestNode_Keys(t *testing.T) {
+	in := `zeta: 1
+alpha: 2
+
+[Mid]
+b: 1
+a: 2
+
+[List]
+- x: 1
+
+beta: 3
+zeta: 4
+`
+	node, err := ParseString(in)
+	if err != nil {
+		t.Fatal("ParseString failed, expected no error, got:", err)
+	}
+
+	expected := []string{"zeta", "alpha", "Mid", "List", "beta"}
+	if keys := node.Keys(); !arrayEquals(keys, expected) || len(keys) != len(expected) {
+		t.Error("Keys failed, expected:", expected, ", got:", keys)
+	}
+
+	mid, _ := node.GetNode("Mid")
+	if keys := mid.Keys(); !arrayEquals(keys, []string{"b", "a"}) || len(keys) != 2 {
+		t.Error("Keys failed, expected: [b a], got:", keys)
+	}
+}
+
+func TestNode_GetMultilineString(t *testing.T) {
+	node, err := ParseString("[Mail]\nbody: \"\"\"-\n\tHello,\n\n\t  \\\"world\\\"\n\t\"\"\"\n")
+	if err != nil {
+		t.Fatal("Parse failed, expected no error, got:", err)
+	}
+
+	s := node.GetString("Mail.body")
+	if s != "Hello,\n\n  \"world\"" {
+		t.Errorf("GetString failed, expected a multi-line string, got: %q", s)
+	}
+}
+
+func TestNode_Set(t *testing.T) {
+	node := NewNode()
+
+	type testCase struct {
+		path     string
+		val      interface{}
+		expected interface{}
+	}
+	cases := []testCase{
+		{"name", "api", "api"},
+		{"server.port", int32(8080), 8080},
+		{"server.tls.enabled", true, true},
+		{"ratio", float32(0.5), 0.5},
+		{"big", uint64(math.MaxUint64), uint64(math.MaxUint64)},
+		{"timeout", 30 * time.Second, 30 * time.Second},
+		{"ports", []int32{80, 443}, []int{80, 443}},
+		{"mixed", []interface{}{1, 2.5}, []float64{1, 2.5}},
+		{"nothing", nil, nil},
+	}
+	for _, c := range cases {
+		if err := node.Set(c.path, c.val); err != nil {
+			t.Error("Set failed, path:", c.path, ", expected no error, got:", err)
+			continue
+		}
+		if v, err := node.Get(c.path); err != nil || !reflect.DeepEqual(v, c.expected) {
+			t.Errorf("Set failed, path: %s, expected: %#v, got: %#v, %v", c.path, c.expected, v, err)
+		}
+	}
+	if keys := node.Keys(); !arrayEquals(keys, []string{"name", "server", "ratio", "big", "timeout", "ports", "mixed", "nothing"}) {
+		t.Error("Set failed, expected the keys in order, got:", keys)
+	}
+
+	if err := node.Set("name.first", "a"); err != errTypeMismatch {
+		t.Error("Set failed, expected error (type mismatch), got:", err)
+	}
+	if err := node.Set("a..b", 1); err != errNoName {
+		t.Error("Set failed, expected error (no name provided), got:", err)
+	}
+	for _, path := range []string{"a b", "x:y", "a[0]", "a#b", "a//b", "a\nb", "server.a b"} {
+		if err := node.Set(path, 1); err != errInvalidName {
+			t.Errorf("Set failed, path: %q, expected error (invalid name), got: %v", path, err)
+		}
+	}
+	if _, err := node.Get("server.a b"); err == nil || len(node.Keys()) != 8 {
+		t.Error("Set failed, expected no key added for an invalid name, got:", node.Keys())
+	}
+	if _, ok := node.Set("m", map[string]int{}).(*UnsupportedTypeError); !ok {
+		t.Error("Set failed, expected an unsupported type error")
+	}
+
+	server, _ := node.GetNode("server")
+	if err := server.SetNode("tls.root", node); err != errNodeCycle {
+		t.Error("SetNode failed, expected error (node cycle), got:", err)
+	}
+	if err := node.SetNode("db", nil); err != errNilNode {
+		t.Error("SetNode failed, expected error (nil node), got:", err)
+	}
+	if err := node.SetNode("backup", server); err != nil || node.GetInt("backup.port") != 8080 {
+		t.Error("SetNode failed, expected no error, got:", err)
+	}
+}
+
+func TestNode_Delete(t *testing.T) {
+	node, _ := ParseString("a: 1\nb: 2\nc: {d: 3}\n")
+
+	if err := node.Delete("b"); err != nil || node.dict["b"] != nil || !arrayEquals(node.Keys(), []string{"a", "c"}) {
+		t.Error("Delete failed, got:", node.Keys(), err)
+	}
+	if err := node.Delete("c.d"); err != nil || node.GetStringOr("c.d", "none") != "none" {
+		t.Error("Delete failed, expected a nested key to be removed, got:", err)
+	}
+	if err := node.Delete("missing"); err != errValueNotFound {
+		t.Error("Delete failed, expected error (value not found), got:", err)
+	}
+}
+
+func TestNode_AppendToList(t *testing.T) {
+	node, _ := ParseString("name: \"a\"\n\n[Servers]\n- host: \"a\"\n")
+	first, _ := node.GetNodeList("Servers")
+
+	server := NewNode()
+	server.Set("host", "b")
+	if err := node.AppendToList("Servers", server); err != nil {
+		t.Fatal("AppendToList failed, expected no error, got:", err)
+	}
+	if list, _ := node.GetNodeList("Servers"); len(list) != 2 || list[1].GetString("host") != "b" || len(first) != 1 {
+		t.Error("AppendToList failed, got:", list)
+	}
+
+	if err := node.AppendToList("db.replicas", server); err != nil {
+		t.Error("AppendToList failed, expected a new list, got:", err)
+	}
+	if list, _ := node.GetNodeList("db.replicas"); len(list) != 1 {
+		t.Error("AppendToList failed, expected a new list, got:", list)
+	}
+	if err := node.AppendToList("name", server); err != errTypeMismatch {
+		t.Error("AppendToList failed, expected error (type mismatch), got:", err)
+	}
+	if err := server.AppendToList("self", server); err != errNodeCycle {
+		t.Error("AppendToList failed, expected error (node cycle), got:", err)
+	}
+}
+
+func TestNode_Range(t *testing.T) {
+	node, _ := ParseString("a: 1\nb: \"x\"\nc: null\nd: {e: 2}\n")
+
+	if node.Len() != 4 || !node.Has("c") || !node.Has("d.e") || node.Has("d.f") || node.Has("a.b") {
+		t.Error("Len and Has failed, got:", node.Len(), node.dict)
+	}
+
+	var keys []string
+	node.Range(func(key string, v Value) bool {
+		keys = append(keys, key+":"+v.Kind().String())
+		if key == "a" {
+			node.Delete("b")
+			node.Set("z", 1)
+		}
+		return key != "c"
+	})
+	if !arrayEquals(keys, []string{"a:int", "c:null"}) {
+		t.Error("Range failed, expected: [a:int c:null], got:", keys)
+	}
+}
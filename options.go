@@ -0,0 +1,81 @@
+package rj
+
+import "time"
+
+// CommentStyle is a set of comment styles.
+type CommentStyle int
+
+const (
+	CommentHash  CommentStyle = 1 << iota // comments starting with '#'
+	CommentSlash                          // comments starting with '//'
+)
+
+// ParseOptions controls how a document is parsed.
+// The zero value gives the default behavior of Parse.
+type ParseOptions struct {
+	// DisableInterpolation leaves ${...} references in strings as they are,
+	// for input which must not read other keys.
+	DisableInterpolation bool
+
+	// AllowEnv lets ${env:NAME} references read the environment variables of the process.
+	// Without it such a reference is an ErrEnvNotAllowed error, so the input cannot read secrets
+	// from the environment.
+	AllowEnv bool
+
+	// Strict reports a key or section which is defined twice in the same node,
+	// instead of letting the later definition replace the earlier one.
+	Strict bool
+
+	// MergeSections combines the sections of the same name: the pairs of a repeated section
+	// are added to the earlier one, and the nodes of a repeated node list are appended to it.
+	MergeSections bool
+
+	// Location is the time zone of dates and times without an offset. It is UTC if nil.
+	Location *time.Location
+
+	// Comments is the set of comment styles allowed. Both styles are allowed if it is zero.
+	Comments CommentStyle
+
+	// AllowIncludes resolves @include directives in input which is not loaded from a file,
+	// relative to the current directory. Without it such a directive is an ErrIncludeNotAllowed error,
+	// as it lets the input read any file. Load, LoadWithOptions and UnmarshalFile always resolve includes,
+	// relative to the loaded file.
+	AllowIncludes bool
+
+	// ShareAliases makes an alias of a node or node list refer to the anchored value itself,
+	// instead of a copy of it, so a change to the node is seen through the anchor and all of its aliases.
+	ShareAliases bool
+
+	// The limits below protect against untrusted input, there is no limit if one is zero.
+	// A value over a limit is reported as an error and not kept, the rest of the document is still scanned.
+
+	// MaxDepth is the maximum nesting depth of arrays and objects, and the maximum number of parts
+	// of a dotted section name.
+	MaxDepth int
+
+	// MaxDocumentSize is the maximum size of the document in bytes, and of each included file.
+	// A larger document is not scanned, a Decoder stops reading at the limit.
+	// The strings built by resolving ${...} references may total at most as many bytes,
+	// and the aliases may copy or share at most as many values, counting the items of arrays.
+	MaxDocumentSize int
+
+	// MaxStringLength is the maximum length of a string value in bytes, also once its references are resolved.
+	MaxStringLength int
+
+	// MaxArrayLength is the maximum number of items of an array or a node list.
+	MaxArrayLength int
+
+	// MaxKeys is the maximum number of keys defined in the document, in all of its nodes.
+	MaxKeys int
+}
+
+func (o ParseOptions) location() *time.Location {
+	if o.Location == nil {
+		return time.UTC
+	}
+	return o.Location
+}
+
+func (o ParseOptions) allowsComment(style CommentStyle) bool {
+	return o.Comments == 0 || o.Comments&style != 0
+}
@@ -0,0 +1,287 @@
+package rj
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseStrict(t *testing.T) {
+	in := `name: "a"
+name: "b"
+child: {age: 1
+	age: 2}
+
+[Server]
+host: "a"
+
+[Server]
+host: "b"
+
+[db.main]
+host: "c"
+
+[db]
+port: 1
+
+[db.main]
+port: 2
+`
+	node, err := ParseWithOptions([]byte(in), ParseOptions{Strict: true})
+	var rjErr *RJError
+	if !errors.As(err, &rjErr) || len(rjErr.Errors) != 4 {
+		t.Fatal("Parse strict failed, expected four errors, got:", err)
+	}
+
+	expected := []struct {
+		name     string
+		code     ErrorCode
+		line     int
+		previous int
+	}{
+		{"name", ErrDuplicateKey, 2, 1},
+		{"age", ErrDuplicateKey, 4, 3},
+		{"Server", ErrDuplicateSection, 9, 6},
+		{"db.main", ErrDuplicateSection, 18, 12},
+	}
+	for i, e := range expected {
+		pe := rjErr.Errors[i]
+		if pe.Name != e.name || pe.Code != e.code || pe.Line != e.line || pe.Previous == nil || pe.Previous.Line != e.previous {
+			t.Error("Parse strict failed, expected error:", e, ", got:", pe)
+		}
+	}
+	if !strings.Contains(rjErr.Errors[0].Error(), "previous definition: line 1, column 1") {
+		t.Error("Parse strict failed, expected the previous position in the message, got:", rjErr.Errors[0])
+	}
+
+	if node.GetString("name") != "a" || node.GetString("Server.host") != "a" || node.GetInt("db.port") != 1 ||
+		node.GetString("db.main.host") != "c" {
+		t.Error("Parse strict failed, expected the first definitions to be kept, got:", node.dict)
+	}
+
+	if _, err = ParseString(in); err != nil {
+		t.Error("Parse failed, expected no error without strict mode, got:", err)
+	}
+}
+
+func TestParseMergeSections(t *testing.T) {
+	in := `[Server]
+host: "a"
+port: 80
+
+[Servers]
+- host: "a"
+
+[Server]
+port: 8080
+
+[Servers]
+- host: "b"
+`
+	node, err := ParseWithOptions([]byte(in), ParseOptions{MergeSections: true})
+	if err != nil {
+		t.Fatal("Parse merge sections failed, expected no error, got:", err)
+	}
+
+	list, _ := node.GetNodeList("Servers")
+	if node.GetString("Server.host") != "a" || node.GetInt("Server.port") != 8080 || len(list) != 2 {
+		t.Error("Parse merge sections failed, got:", node.dict)
+	}
+
+	node, _ = ParseString(in)
+	if node.GetString("Server.host") != "" {
+		t.Error("Parse failed, expected a repeated section to replace the earlier one, got:", node.dict)
+	}
+
+	_, err = ParseWithOptions([]byte(in), ParseOptions{MergeSections: true, Strict: true})
+	if !errors.Is(err, ErrDuplicateKey) || errors.Is(err, ErrDuplicateSection) {
+		t.Error("Parse merge sections failed, expected duplicate keys in merged sections, got:", err)
+	}
+}
+
+func TestParseLocation(t *testing.T) {
+	loc := time.FixedZone("CST", 8*60*60)
+	in := "date: 2019-10-11\nat: 2019-10-11 12:03:04\nutc: 2019-10-11T12:03:04Z\n"
+
+	node, err := ParseWithOptions([]byte(in), ParseOptions{Location: loc})
+	if err != nil {
+		t.Fatal("Parse with location failed, expected no error, got:", err)
+	}
+	if at := node.GetTime("at"); !at.Equal(time.Date(2019, 10, 11, 12, 3, 4, 0, loc)) || at.Location() != loc {
+		t.Error("Parse with location failed, expected a time in CST, got:", at)
+	}
+	if d := node.GetTime("date"); d.Location() != loc {
+		t.Error("Parse with location failed, expected a date in CST, got:", d)
+	}
+	if utc := node.GetTime("utc"); !utc.Equal(time.Date(2019, 10, 11, 12, 3, 4, 0, time.UTC)) {
+		t.Error("Parse with location failed, expected the offset of the value to be kept, got:", utc)
+	}
+
+	node, _ = ParseString(in)
+	if at := node.GetTime("at"); at.Location() != time.UTC {
+		t.Error("Parse failed, expected a time in UTC by default, got:", at)
+	}
+}
+
+func TestParseComments(t *testing.T) {
+	in := "# hash\nurl: `//host` // slash\n"
+
+	if _, err := ParseWithOptions([]byte(in), ParseOptions{Comments: CommentHash | CommentSlash}); err != nil {
+		t.Error("Parse with both comment styles failed, expected no error, got:", err)
+	}
+	if _, err := ParseWithOptions([]byte(in), ParseOptions{Comments: CommentSlash}); err == nil {
+		t.Error("Parse with slash comments failed, expected error for a hash comment")
+	}
+
+	node, err := ParseWithOptions([]byte("# hash\ncolor: `#fff` # comment\n"), ParseOptions{Comments: CommentHash})
+	if err != nil || node.GetString("color") != "#fff" {
+		t.Error("Parse with hash comments failed, got:", node.dict, err)
+	}
+	if _, err = ParseWithOptions([]byte("// slash\n"), ParseOptions{Comments: CommentHash}); err == nil {
+		t.Error("Parse with hash comments failed, expected error for a slash comment")
+	}
+}
+
+func TestParseMaxDepth(t *testing.T) {
+	in := "ok: [[1], {a: [2]}]\ndeep: {a: {b: [[3]]}}\nafter: 1\n"
+
+	node, err := ParseWithOptions([]byte(in), ParseOptions{MaxDepth: 3})
+	var rjErr *RJError
+	if !errors.As(err, &rjErr) || len(rjErr.Errors) != 1 || rjErr.Errors[0].Code != ErrMaxDepth {
+		t.Fatal("Parse with max depth failed, expected one error (max depth), got:", err)
+	}
+	if pe := rjErr.Errors[0]; pe.Line != 2 || pe.Column != 16 {
+		t.Error("Parse with max depth failed, expected error at line 2, column 16, got:", pe)
+	}
+	if node.GetInt("after") != 1 || node.dict["ok"] == nil {
+		t.Error("Parse with max depth failed, expected the other values to be kept, got:", node.dict)
+	}
+
+	if _, err = ParseString(in); err != nil {
+		t.Error("Parse failed, expected no limit by default, got:", err)
+	}
+}
+
+func TestLoadWithOptions(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"main.rj": "[Server]\nhost: \"a\"\n// not a comment\nport: 80\n",
+	})
+
+	_, err := LoadWithOptions(filepath.Join(dir, "main.rj"), ParseOptions{Comments: CommentHash})
+	var rjErr *RJError
+	if !errors.As(err, &rjErr) || len(rjErr.Errors) != 1 || rjErr.Errors[0].Line != 3 {
+		t.Error("Load with options failed, expected one error at line 3, got:", err)
+	}
+
+	node, err := Load(filepath.Join(dir, "main.rj"))
+	if err != nil || node.GetInt("port") != 80 {
+		t.Error("Load failed, expected the comment to end the section, got:", node.dict, err)
+	}
+}
+
+func TestParseLimits(t *testing.T) {
+	type testCase struct {
+		in     string
+		opts   ParseOptions
+		code   ErrorCode
+		line   int
+		column int
+	}
+
+	cases := []testCase{
+		{"a: 1\nb: 2\n", ParseOptions{MaxDocumentSize: 7}, ErrDocumentTooLarge, 2, 3},
+		{"a: \"12345\"\nb: `123456`\nc: \"\"\"\n1234\n\"\"\"\n", ParseOptions{MaxStringLength: 5}, ErrStringTooLong, 2, 4},
+		{"a: [1, 2, 3]\nb: [1, 2, 3, [4]]\n", ParseOptions{MaxArrayLength: 3}, ErrArrayTooLong, 2, 14},
+		{"[A]\n- a: 1\n- a: 2\n- a: 3\n", ParseOptions{MaxArrayLength: 2}, ErrArrayTooLong, 4, 1},
+		{"a: 1\nb: {c: 2}\n[D]\ne: 3\n", ParseOptions{MaxKeys: 3}, ErrTooManyKeys, 3, 1},
+		{"[a.b]\nc: 1\n\n[a.b.c]\nd: 1\n", ParseOptions{MaxDepth: 2}, ErrMaxDepth, 4, 1},
+	}
+
+	for _, c := range cases {
+		_, err := ParseWithOptions([]byte(c.in), c.opts)
+		var rjErr *RJError
+		if !errors.As(err, &rjErr) || len(rjErr.Errors) != 1 {
+			t.Error("Parse with limits failed, input:", c.in, ", expected one error, got:", err)
+			continue
+		}
+		if pe := rjErr.Errors[0]; pe.Code != c.code || pe.Line != c.line || pe.Column != c.column {
+			t.Error("Parse with limits failed, input:", c.in, ", expected:", c.code, c.line, c.column, ", got:", pe)
+		}
+
+		if _, err = ParseString(c.in); err != nil {
+			t.Error("Parse failed, input:", c.in, ", expected no error without limits, got:", err)
+		}
+	}
+
+	node, _ := ParseWithOptions([]byte("a: [1, 2, 3]\nb: 1\n[C]\nd: 1\ne: 2\n"), ParseOptions{MaxArrayLength: 2, MaxKeys: 3})
+	if node.dict["a"] != nil || node.GetInt("b") != 1 || node.GetInt("C.d") != 1 || node.dict["C"].(*Node).dict["e"] != nil {
+		t.Error("Parse with limits failed, expected the values over the limits to be dropped, got:", node.dict)
+	}
+}
+
+func TestParseLimitsInterpolation(t *testing.T) {
+	// every line is four times as long as the previous one once resolved
+	var b strings.Builder
+	b.WriteString("a0: \"0123456789\"\n")
+	for i := 1; i <= 12; i++ {
+		p := "${a" + strconv.Itoa(i-1) + "}"
+		b.WriteString("a" + strconv.Itoa(i) + ": \"" + p + p + p + p + "\"\n")
+	}
+	in := []byte(b.String())
+
+	cases := []struct {
+		opts ParseOptions
+		code ErrorCode
+		line int
+	}{
+		{ParseOptions{MaxStringLength: 100}, ErrStringTooLong, 3},
+		{ParseOptions{MaxDocumentSize: 1000}, ErrDocumentTooLarge, 5},
+	}
+	for _, c := range cases {
+		node, err := ParseWithOptions(in, c.opts)
+		var rjErr *RJError
+		if !errors.As(err, &rjErr) || len(rjErr.Errors) != 1 {
+			t.Error("Parse with limits failed, expected one error, got:", err)
+			continue
+		}
+		if pe := rjErr.Errors[0]; pe.Code != c.code || pe.Line != c.line {
+			t.Error("Parse with limits failed, expected:", c.code, c.line, ", got:", pe)
+		}
+		if s := node.GetString("a12"); s != "${a11}${a11}${a11}${a11}" {
+			t.Error("Parse with limits failed, expected the string over the limit not to be resolved, got:", len(s))
+		}
+	}
+
+	os.Setenv("RJ_TEST_LONG", strings.Repeat("x", 20))
+	defer os.Unsetenv("RJ_TEST_LONG")
+	_, err := ParseWithOptions([]byte(`a: "${env:RJ_TEST_LONG}"`), ParseOptions{AllowEnv: true, MaxStringLength: 10})
+	if !errors.Is(err, ErrStringTooLong) {
+		t.Error("Parse with limits failed, expected error (string too long) for an environment variable, got:", err)
+	}
+}
+
+func TestDecoderMaxDocumentSize(t *testing.T) {
+	in := "a: 1\n\n[B]\nc: 2\n" + strings.Repeat("d: 3\n", 1000)
+
+	d := NewDecoder(strings.NewReader(in))
+	d.SetOptions(ParseOptions{MaxDocumentSize: 32})
+	node, err := d.DecodeNode()
+	if !errors.Is(err, ErrDocumentTooLarge) || node.GetInt("a") != 1 {
+		t.Error("Decoder with max document size failed, expected error (document too large), got:", node.dict, err)
+	}
+
+	dir := writeFiles(t, map[string]string{
+		"main.rj":  "@include \"large.rj\"\nb: 1\n",
+		"large.rj": in,
+	})
+	node, err = LoadWithOptions(filepath.Join(dir, "main.rj"), ParseOptions{MaxDocumentSize: 32})
+	var rjErr *RJError
+	if !errors.As(err, &rjErr) || len(rjErr.Errors) != 1 || filepath.Base(rjErr.Errors[0].File) != "large.rj" ||
+		node.GetInt("b") != 1 {
+		t.Error("Load with max document size failed, expected the included file to be too large, got:", err)
+	}
+}
@@ -0,0 +1,413 @@
+package rj
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+var errInvalidQuery = errors.New("invalid query")
+
+// A query is a path to values of a node, like
+//
+//	servers[0].host                  the host of the first node of the list
+//	servers[-1]                      the last node of the list
+//	servers[*].port                  the ports of all the nodes
+//	servers[?(@.region=="eu")].host  the hosts of the nodes whose region is "eu"
+//
+// An index selects an item of a node list or an array, counted from the end if it is negative.
+// [*] selects all the items, or all the values of a node. A filter selects the items for which
+// its condition holds: @ is the item, followed by dotted names in it, and is compared with
+// a string, number, bool or null by ==, !=, <, <=, > or >=. A filter without a comparison,
+// like [?(@.tls)], selects the items which have a value at the path.
+//
+// A query with [*] or a filter may match any number of values, it is a multi-match query.
+type query struct {
+	selectors []selector
+	multi     bool
+}
+
+type selectorKind int
+
+const (
+	selectName selectorKind = iota
+	selectIndex
+	selectAll
+	selectFilter
+)
+
+type selector struct {
+	kind   selectorKind
+	name   string
+	index  int
+	filter *filter
+}
+
+// filter is the condition of a [?(...)] selector.
+type filter struct {
+	path []string // the names after @
+	op   string   // the comparison, or empty to test that the value exists
+	val  interface{}
+}
+
+// Query returns the values matched by a query path, like "servers[*].port", in document order.
+// A path without selectors, like "server.port", matches at most one value.
+// It returns an error only if the path is invalid.
+func (n *Node) Query(path string) ([]interface{}, error) {
+	q, err := parseQuery(path)
+	if err != nil {
+		return nil, err
+	}
+	return q.eval(n), nil
+}
+
+// query gets the value of a query path: the value matched, or a slice of the values
+// matched by a multi-match query, typed like the arrays of a document.
+func (n *Node) query(path string) (interface{}, error) {
+	q, err := parseQuery(path)
+	if err != nil {
+		return nil, err
+	}
+
+	matches := q.eval(n)
+	if q.multi {
+		return typedArray(matches), nil
+	}
+	if len(matches) == 0 {
+		return nil, errValueNotFound
+	}
+	return matches[0], nil
+}
+
+func (q *query) eval(root *Node) []interface{} {
+	values := []interface{}{root}
+	for _, sel := range q.selectors {
+		next := []interface{}{}
+		for _, v := range values {
+			next = sel.apply(v, next)
+		}
+		values = next
+	}
+	return values
+}
+
+// apply appends the values that the selector matches in v to matches.
+func (sel *selector) apply(v interface{}, matches []interface{}) []interface{} {
+	if sel.kind == selectName {
+		if n, ok := v.(*Node); ok {
+			if val, ok := n.dict[sel.name]; ok {
+				matches = append(matches, val)
+			}
+		}
+		return matches
+	}
+
+	if n, ok := v.(*Node); ok && sel.kind == selectAll {
+		for _, k := range n.keys {
+			matches = append(matches, n.dict[k])
+		}
+		return matches
+	}
+
+	items := itemsOf(v)
+	switch sel.kind {
+	case selectIndex:
+		i := sel.index
+		if i < 0 {
+			i += len(items)
+		}
+		if i >= 0 && i < len(items) {
+			matches = append(matches, items[i])
+		}
+	case selectAll:
+		matches = append(matches, items...)
+	case selectFilter:
+		for _, item := range items {
+			if sel.filter.match(item) {
+				matches = append(matches, item)
+			}
+		}
+	}
+	return matches
+}
+
+// itemsOf returns the items of a node list or an array, or nil for other values.
+func itemsOf(v interface{}) []interface{} {
+	switch vt := v.(type) {
+	case []interface{}:
+		return vt
+	case []*Node:
+		items := make([]interface{}, len(vt))
+		for i, n := range vt {
+			items[i] = n
+		}
+		return items
+	}
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice {
+		return nil
+	}
+	items := make([]interface{}, rv.Len())
+	for i := range items {
+		items[i] = rv.Index(i).Interface()
+	}
+	return items
+}
+
+func (f *filter) match(item interface{}) bool {
+	v := item
+	for _, name := range f.path {
+		n, ok := v.(*Node)
+		if !ok {
+			return false
+		}
+		if v, ok = n.dict[name]; !ok {
+			return false
+		}
+	}
+
+	if f.op == "" {
+		return true
+	}
+	return compare(v, f.val, f.op)
+}
+
+// compare compares a value with the value of a filter.
+// Numbers are compared as numbers whatever their type, strings in byte order,
+// other values can only be equal or not.
+func compare(a, b interface{}, op string) bool {
+	c, ordered := 0, false
+	if x, ok := toFloat(a); ok {
+		if y, ok := toFloat(b); ok {
+			c, ordered = cmpFloat(x, y), true
+		}
+	} else if x, ok := a.(string); ok {
+		if y, ok := b.(string); ok {
+			c, ordered = strings.Compare(x, y), true
+		}
+	}
+
+	if !ordered {
+		equal := (a == nil && b == nil) || (a != nil && reflect.TypeOf(a).Comparable() && a == b)
+		switch op {
+		case "==":
+			return equal
+		case "!=":
+			return !equal
+		}
+		return false
+	}
+
+	switch op {
+	case "==":
+		return c == 0
+	case "!=":
+		return c != 0
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	case ">=":
+		return c >= 0
+	}
+	return false
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch vt := v.(type) {
+	case int:
+		return float64(vt), true
+	case int64:
+		return float64(vt), true
+	case uint64:
+		return float64(vt), true
+	case float64:
+		return vt, true
+	}
+	return 0, false
+}
+
+func cmpFloat(x, y float64) int {
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	}
+	return 0
+}
+
+// queryParser parses a query path.
+type queryParser struct {
+	s string
+	i int
+}
+
+func parseQuery(path string) (*query, error) {
+	p := &queryParser{s: path}
+	q := &query{}
+	for {
+		name := p.name(".[")
+		if name == "" {
+			return nil, errInvalidQuery
+		}
+		q.selectors = append(q.selectors, selector{kind: selectName, name: name})
+
+		for p.i < len(p.s) && p.s[p.i] == '[' {
+			sel, err := p.bracket()
+			if err != nil {
+				return nil, err
+			}
+			q.multi = q.multi || sel.kind == selectAll || sel.kind == selectFilter
+			q.selectors = append(q.selectors, sel)
+		}
+
+		if p.i == len(p.s) {
+			return q, nil
+		}
+		if p.s[p.i] != '.' {
+			return nil, errInvalidQuery
+		}
+		p.i++
+	}
+}
+
+// name scans a name, up to one of the stop bytes.
+func (p *queryParser) name(stop string) string {
+	start := p.i
+	for p.i < len(p.s) && strings.IndexByte(stop, p.s[p.i]) < 0 {
+		p.i++
+	}
+	return p.s[start:p.i]
+}
+
+// bracket scans a selector between brackets: an index, * or a filter.
+func (p *queryParser) bracket() (sel selector, err error) {
+	p.i++ // skip '['
+	end := strings.IndexByte(p.s[p.i:], ']')
+	switch {
+	case strings.HasPrefix(p.s[p.i:], "?("):
+		p.i += 2
+		f, err := p.filter()
+		if err != nil {
+			return sel, err
+		}
+		sel = selector{kind: selectFilter, filter: f}
+	case strings.HasPrefix(p.s[p.i:], "*"):
+		p.i++
+		sel = selector{kind: selectAll}
+	case end > 0:
+		i, err := strconv.Atoi(p.s[p.i : p.i+end])
+		if err != nil {
+			return sel, errInvalidQuery
+		}
+		p.i += end
+		sel = selector{kind: selectIndex, index: i}
+	default:
+		return sel, errInvalidQuery
+	}
+
+	if p.i >= len(p.s) || p.s[p.i] != ']' {
+		return sel, errInvalidQuery
+	}
+	p.i++
+	return sel, nil
+}
+
+// filter scans the condition of a filter, after "?(" and up to its ')'.
+func (p *queryParser) filter() (*filter, error) {
+	p.skipSpace()
+	if p.i >= len(p.s) || p.s[p.i] != '@' {
+		return nil, errInvalidQuery
+	}
+	p.i++
+
+	f := &filter{}
+	for p.i < len(p.s) && p.s[p.i] == '.' {
+		p.i++
+		name := p.name(".[]()=!<> \t")
+		if name == "" {
+			return nil, errInvalidQuery
+		}
+		f.path = append(f.path, name)
+	}
+
+	p.skipSpace()
+	for _, op := range []string{"==", "!=", "<=", ">=", "<", ">"} {
+		if strings.HasPrefix(p.s[p.i:], op) {
+			f.op = op
+			p.i += len(op)
+			break
+		}
+	}
+	if f.op != "" {
+		p.skipSpace()
+		v, err := p.literal()
+		if err != nil {
+			return nil, err
+		}
+		f.val = v
+		p.skipSpace()
+	}
+
+	if p.i >= len(p.s) || p.s[p.i] != ')' {
+		return nil, errInvalidQuery
+	}
+	p.i++
+	return f, nil
+}
+
+// literal scans the value a filter compares with: a string between double or single quotes,
+// a number, true, false or null.
+func (p *queryParser) literal() (interface{}, error) {
+	if p.i >= len(p.s) {
+		return nil, errInvalidQuery
+	}
+
+	if q := p.s[p.i]; q == '"' || q == '\'' {
+		var b strings.Builder
+		for p.i++; p.i < len(p.s); p.i++ {
+			c := p.s[p.i]
+			if c == '\\' && p.i+1 < len(p.s) {
+				p.i++
+				b.WriteByte(p.s[p.i])
+				continue
+			}
+			if c == q {
+				p.i++
+				return b.String(), nil
+			}
+			b.WriteByte(c)
+		}
+		return nil, errInvalidQuery
+	}
+
+	start := p.i
+	for p.i < len(p.s) && p.s[p.i] != ')' && !isSpace(p.s[p.i]) {
+		p.i++
+	}
+	switch raw := p.s[start:p.i]; raw {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "null":
+		return nil, nil
+	default:
+		v, err := decodeNumber(raw)
+		if err != nil {
+			return nil, errInvalidQuery
+		}
+		return v, nil
+	}
+}
+
+func (p *queryParser) skipSpace() {
+	for p.i < len(p.s) && isSpace(p.s[p.i]) {
+		p.i++
+	}
+}
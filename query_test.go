@@ -0,0 +1,102 @@
+package rj
+
+import (
+	"reflect"
+	"testing"
+)
+
+const queryDoc = `name: "api"
+ports: [80, 443, 8080]
+a[0]: "literal"
+
+[servers]
+- host: "a.eu"
+  region: "eu"
+  port: 80
+  tls: {enabled: true}
+- host: "b.us"
+  region: "us"
+  port: 8080
+- host: "c.eu"
+  region: "eu"
+  port: 9090
+`
+
+func TestNode_Query(t *testing.T) {
+	node, err := ParseString(queryDoc)
+	if err != nil {
+		t.Fatal("ParseString failed, expected no error, got:", err)
+	}
+
+	type testCase struct {
+		path     string
+		expected []interface{}
+	}
+	cases := []testCase{
+		{"name", []interface{}{"api"}},
+		{"missing", []interface{}{}},
+		{"ports[1]", []interface{}{443}},
+		{"ports[-1]", []interface{}{8080}},
+		{"ports[3]", []interface{}{}},
+		{"servers[0].host", []interface{}{"a.eu"}},
+		{"servers[-1].port", []interface{}{9090}},
+		{"servers[*].port", []interface{}{80, 8080, 9090}},
+		{`servers[?(@.region=="eu")].host`, []interface{}{"a.eu", "c.eu"}},
+		{`servers[?(@.region == 'us')].host`, []interface{}{"b.us"}},
+		{"servers[?(@.port >= 8080)].host", []interface{}{"b.us", "c.eu"}},
+		{"servers[?(@.port != 80)].host", []interface{}{"b.us", "c.eu"}},
+		{"servers[?(@.tls.enabled == true)].host", []interface{}{"a.eu"}},
+		{"servers[?(@.tls)].host", []interface{}{"a.eu"}},
+		{"servers[?(@.port < \"z\")].host", []interface{}{}},
+		{"ports[?(@ > 100)]", []interface{}{443, 8080}},
+		{"servers[0].tls[*]", []interface{}{true}},
+	}
+	for _, c := range cases {
+		v, err := node.Query(c.path)
+		if err != nil || !reflect.DeepEqual(v, c.expected) {
+			t.Errorf("Query failed, path: %s, expected: %v, got: %v, %v", c.path, c.expected, v, err)
+		}
+	}
+
+	for _, path := range []string{"", "a.", ".a", "ports[", "ports[x]", "ports[]", "ports[0", "ports[0]x",
+		"servers[?(@.port >)]", "servers[?(port == 1)]", `servers[?(@.host == "a)]`, "servers[?(@.port == 1]"} {
+		if _, err := node.Query(path); err != errInvalidQuery {
+			t.Error("Query failed, path:", path, ", expected error (invalid query), got:", err)
+		}
+	}
+}
+
+func TestGetQuery(t *testing.T) {
+	node, _ := ParseString(queryDoc)
+
+	if node.GetString("servers[1].host") != "b.us" || node.GetInt("servers[-1].port") != 9090 {
+		t.Error("Get with a query failed, got:", node.GetString("servers[1].host"), node.GetInt("servers[-1].port"))
+	}
+	if ports := node.GetIntArray("servers[*].port"); !reflect.DeepEqual(ports, []int{80, 8080, 9090}) {
+		t.Error("GetIntArray with a query failed, expected: [80 8080 9090], got:", ports)
+	}
+	if hosts := node.GetStringArray(`servers[?(@.region=="eu")].host`); !arrayEquals(hosts, []string{"a.eu", "c.eu"}) {
+		t.Error("GetStringArray with a query failed, expected: [a.eu c.eu], got:", hosts)
+	}
+	if v, err := node.Get(`servers[?(@.region=="asia")]`); err != nil || !reflect.DeepEqual(v, []interface{}{}) {
+		t.Error("Get with a query failed, expected an empty array, got:", v, err)
+	}
+	if list, err := node.GetNodeList(`servers[?(@.port>100)]`); err != nil || len(list) != 2 {
+		t.Error("GetNodeList with a query failed, expected two nodes, got:", list, err)
+	}
+	if n, err := node.GetNode("servers[0].tls"); err != nil || !n.GetBool("enabled") {
+		t.Error("GetNode with a query failed, got:", n, err)
+	}
+
+	if _, err := node.Get("servers[5].host"); err != errValueNotFound {
+		t.Error("Get with a query failed, expected error (value not found), got:", err)
+	}
+	if node.GetString("a[0]") != "literal" {
+		t.Error("Get failed, expected a key with brackets, got:", node.GetString("a[0]"))
+	}
+	for _, path := range []string{"servers[ 0 ]", "servers[?(@.port==)]", "a[1]x"} {
+		if _, err := node.GetIntOrError(path); err != errInvalidQuery {
+			t.Error("Get with a query failed, path:", path, ", expected error (invalid query), got:", err)
+		}
+	}
+}
@@ -1,36 +1,44 @@
 package rj
 
 import (
-	"errors"
-	"io/ioutil"
-	"log"
+	"bytes"
+	"os"
 )
 
-// Load loads a file of given path and parse it into a node
+// Load loads a file of given path and parse it into a node.
+// Files included with @include are resolved relative to the file.
 func Load(path string) (node *Node, err error) {
-	bytes, err := ioutil.ReadFile(path)
+	return LoadWithOptions(path, ParseOptions{})
+}
+
+// LoadWithOptions loads a file of given path and parse it into a node, as controlled by opts.
+func LoadWithOptions(path string, opts ParseOptions) (node *Node, err error) {
+	f, err := os.Open(path)
 	if err != nil {
 		return
 	}
-	node, err = Parse(bytes)
+	defer f.Close()
 
-	return
+	d := NewDecoder(f)
+	d.SetOptions(opts)
+	d.scanner.setFile(path)
+	return d.DecodeNode()
 }
 
 // Parse parses given bytes into a node
 func Parse(input []byte) (node *Node, err error) {
-	defer func() {
-		if r := recover(); r != nil {
-			log.Println(r)
-			err = errors.New("parse RJ failed")
-		}
-	}()
+	return ParseWithOptions(input, ParseOptions{})
+}
 
+// ParseWithOptions parses given bytes into a node, as controlled by opts.
+func ParseWithOptions(input []byte, opts ParseOptions) (node *Node, err error) {
 	scanner := newScanner(input)
+	scanner.opts = opts
 	scanner.scan()
+	scanner.finish()
 
 	node = scanner.root
-	if scanner.error != nil && scanner.error.msg != "" {
+	if scanner.error != nil && len(scanner.error.Errors) > 0 {
 		err = scanner.error
 	}
 	return
@@ -41,16 +49,35 @@ func ParseString(input string) (node *Node, err error) {
 	return Parse([]byte(input))
 }
 
-// Marshal encodes a value to RJ bytes
+// Marshal encodes a value to RJ bytes.
+// It returns nil if the value cannot be encoded, use MarshalE to get the error.
 func Marshal(v interface{}) []byte {
-	e := newEncoder(v)
-	return e.encode()
+	b, _ := MarshalE(v)
+	return b
+}
+
+// MarshalE encodes a value to RJ bytes.
+// It returns an *UnsupportedTypeError if the value contains maps, channels, functions or other types RJ cannot represent.
+func MarshalE(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
 }
 
-// Marshal encodes a value to RJ bytes
-func MarshalToFile(v interface{}, filename string) error {
-	bytes := Marshal(v)
-	return ioutil.WriteFile(filename, bytes, 0644)
+// MarshalToFile encodes a value to a RJ file
+func MarshalToFile(v interface{}, filename string) (err error) {
+	f, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return
+	}
+
+	err = NewEncoder(f).Encode(v)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return
 }
 
 // Unmarshal decode RJ bytes to struct value
@@ -71,10 +98,13 @@ func Unmarshal(data []byte, v interface{}) (err error) {
 
 // UnmarshalFile decode a RJ file to struct
 func UnmarshalFile(path string, v interface{}) (err error) {
-	bytes, err := ioutil.ReadFile(path)
+	f, err := os.Open(path)
 	if err != nil {
 		return
 	}
+	defer f.Close()
 
-	return Unmarshal(bytes, v)
+	d := NewDecoder(f)
+	d.scanner.setFile(path)
+	return d.Decode(v)
 }
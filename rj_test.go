@@ -1,6 +1,9 @@
 package rj
 
-import "testing"
+import (
+	"errors"
+	"testing"
+)
 
 func TestParse(t *testing.T) {
 	in := `name: "a\n"
@@ -82,3 +85,68 @@ Age: 12
 	}
 
 }
+
+func TestParseErrors(t *testing.T) {
+	in := `name: "Zoe"
+port: "80\c"
+
+[Server]
+host: "localhost"
+ids: [1, x]
+`
+	_, err := ParseString(in)
+	rjErr, ok := err.(*RJError)
+	if !ok {
+		t.Fatal("ParseString failed, expected *RJError, got:", err)
+	}
+
+	expected := []ParseError{
+		{Position: Position{Offset: 21, Line: 2, Column: 10}, Name: "port", Code: ErrInvalidEscape},
+		{Position: Position{Offset: 62, Line: 6, Column: 10}, Name: "ids", Code: ErrInvalidValue},
+	}
+	if len(rjErr.Errors) != len(expected) {
+		t.Fatal("ParseString failed, expected", len(expected), "errors, got:", rjErr.Errors)
+	}
+	for i, pe := range rjErr.Errors {
+		if *pe != expected[i] {
+			t.Error("ParseString failed, expected error:", expected[i], ", got:", *pe)
+		}
+	}
+
+	if !errors.Is(err, ErrInvalidEscape) {
+		t.Error("errors.Is failed, expected the error list to contain ErrInvalidEscape")
+	}
+
+	msg := "line 2, column 10: invalid escape, name: port"
+	if rjErr.Errors[0].Error() != msg {
+		t.Error("ParseError.Error failed, expected:", msg, ", got:", rjErr.Errors[0].Error())
+	}
+}
+
+func TestParseTruncated(t *testing.T) {
+	in := `name: "Zoe汉" # comment
+passed: true
+scores: [1, 2, 3]
+raw: ` + "`raw`" + `
+child: {
+	age: 12
+}
+
+[Servers]
+- host: "a"
+  port: 80
+- host: "b"
+`
+	// every prefix of a valid document must be handled without panicking
+	for i := 0; i <= len(in); i++ {
+		ParseString(in[:i])
+	}
+
+	cases := []string{`name: "Zoe`, `name: "Zoe\`, `name:`, `scores: [1, 2`, `child: {age: 12`, "raw: `abc"}
+	for _, in := range cases {
+		_, err := ParseString(in)
+		if !errors.Is(err, ErrUnexpectedEOF) {
+			t.Error("ParseString failed, input:", in, ", expected unexpected end of input, got:", err)
+		}
+	}
+}
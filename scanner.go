@@ -1,26 +1,15 @@
 package rj
 
 import (
-	"errors"
-	"strconv"
+	"bytes"
+	"math"
+	"sort"
 	"strings"
 	"time"
 	"unicode/utf8"
 )
 
-const (
-	delimiter              = ':'
-	invalidNodeName        = "invalid node name"
-	invalidName            = "invalid name"
-	invalidValue           = "invalid value"
-	invalidArray           = "invalid array"
-	invalidStringValue     = "invalid string value"
-	invalidUTF8StringValue = "invalid utf-8 string value"
-	invalidBoolValue       = "invalid bool value"
-	invalidNullValue       = "invalid null value"
-	invalidEscape          = "invalid escape"
-	invalidObject          = "invalid object"
-)
+const delimiter = ':'
 
 type scanState int
 
@@ -39,269 +28,573 @@ type scanner struct {
 	root   *Node
 	error  *RJError
 	state  scanState
-	line   int
+
+	// position of data in the whole input, when it is fed block by block
+	baseOffset int
+	baseLine   int
+	lineStarts []int // offsets of the lines of data, built on demand
+
+	implicit map[*Node]bool // nodes which are extended by a section of their name: created by dotted names, or included
+
+	file     string   // the file the data comes from, if any
+	includes []string // absolute paths of the files being scanned, to detect include cycles
+
+	opts    ParseOptions
+	defined map[slot]Position // where keys and sections are defined, in strict mode
+	depth   int               // depth of the arrays and objects being scanned
+	keys    int               // number of keys defined, for MaxKeys
+	aliased int               // number of values copied or shared by aliases, for MaxDocumentSize
+
+	inherits map[*Node]inheritance  // sections which inherit from another one
+	anchors  map[string]interface{} // anchored values, by name
 }
 
 func newScanner(in []byte) *scanner {
 	node := NewNode()
-	return &scanner{data: in, len: len(in), root: node, line: 1 /*,currentNode:node*/}
+	return &scanner{data: in, len: len(in), root: node /*,currentNode:node*/}
 }
 
-func (s *scanner) char() byte {
-	return s.data[s.offset]
+// feed replaces the data with the next block of the input.
+// A block always starts at the beginning of a line.
+func (s *scanner) feed(in []byte) {
+	s.baseOffset += s.len
+	s.baseLine += bytes.Count(s.data, []byte{'\n'})
+	s.data, s.len, s.offset = in, len(in), 0
+	s.lineStarts = nil
 }
 
-func (s *scanner) addErrorMsg(msg string) {
-	if s.error == nil {
-		s.error = newError(msg)
-	} else {
-		s.error.addMsg(msg)
+// position converts a byte offset of the data to a position in the input.
+func (s *scanner) position(offset int) Position {
+	if offset > s.len {
+		offset = s.len
+	}
+	if s.lineStarts == nil {
+		s.lineStarts = []int{0}
+		for i, c := range s.data {
+			if c == '\n' {
+				s.lineStarts = append(s.lineStarts, i+1)
+			}
+		}
+	}
+
+	line := sort.SearchInts(s.lineStarts, offset+1) - 1
+	return Position{
+		Offset: s.baseOffset + offset,
+		Line:   s.baseLine + line + 1,
+		Column: utf8.RuneCount(s.data[s.lineStarts[line]:offset]) + 1,
 	}
 }
 
-func (s *scanner) addError(err error) {
-	s.addErrorMsg(err.Error())
+// errorAt creates an error positioned at the given offset.
+func (s *scanner) errorAt(code ErrorCode, offset int) *ParseError {
+	return &ParseError{Position: s.position(offset), File: s.file, Code: code}
+}
+
+// addError records err, which happened at offset while scanning the named key or section.
+// Errors that already carry a position keep it.
+func (s *scanner) addError(err error, name string, offset int) {
+	pe, ok := err.(*ParseError)
+	if !ok {
+		pe = newError(ErrInvalidValue)
+	}
+	if pe.Line == 0 {
+		pe.Position = s.position(offset)
+		pe.File = s.file
+	}
+	if pe.Name == "" {
+		pe.Name = name
+	}
+
+	if s.error == nil {
+		s.error = &RJError{}
+	}
+	s.error.add(pe)
 }
 
 func (s *scanner) scan() {
-	for s.offset < s.len-1 {
+	if max := s.opts.MaxDocumentSize; max > 0 && s.baseOffset+s.len > max {
+		s.addError(s.errorAt(ErrDocumentTooLarge, max-s.baseOffset), "", 0)
+		s.offset = s.len
+		return
+	}
+
+	for {
 		s.skip()
+		if s.offset >= s.len {
+			return
+		}
 
 		if s.data[s.offset] == '[' {
 			s.scanNode(s.root)
-		} else if s.isComment() {
+		} else if s.data[s.offset] == '@' {
+			s.scanDirective(s.root)
 			s.skipRestOfLine()
 		} else {
 			s.scanPair(s.root)
+			s.skipRestOfLine()
 		}
+	}
+}
 
-		s.skipRestOfLine()
+// finish resolves what needs the whole document, after the last call of scan:
+// the inheritance of sections, then the references in strings.
+// The problems found in references are at the string, their Name is the path of the value.
+func (s *scanner) finish() {
+	s.inheritSections()
+	if !s.opts.DisableInterpolation {
+		for _, pe := range interpolate(s.root, s.opts) {
+			if s.error == nil {
+				s.error = &RJError{}
+			}
+			s.error.add(pe)
+		}
 	}
 }
 
 func (s *scanner) scanPair(parent *Node) {
+	start := s.offset
 	name := s.scanName()
 	if name == "" {
-		s.addErrorMsg(invalidName)
+		s.addError(newError(ErrInvalidName), "", start)
 		s.skipRestOfLine()
 		return
 	}
 
 	s.skipSpace()
+	valStart := s.offset
 	val, err := s.scanValue()
 	if err != nil {
-		s.addErrorMsg(err.Error() + ", name: " + name)
-	} else {
-		//s.addPair(name,val)
-		parent.dict[name] = val
+		s.addError(err, name, valStart)
+	} else if s.countKey(name, start) && s.define(parent, name, name, start, ErrDuplicateKey) {
+		parent.set(name, val)
 	}
 }
 
+// countKey counts a key defined at offset by the named pair or section.
+// It returns false if there are more keys than MaxKeys, the first key over the limit is reported.
+func (s *scanner) countKey(name string, offset int) bool {
+	s.keys++
+	if s.opts.MaxKeys == 0 || s.keys <= s.opts.MaxKeys {
+		return true
+	}
+	if s.keys == s.opts.MaxKeys+1 {
+		s.addError(s.errorAt(ErrTooManyKeys, offset), name, offset)
+	}
+	return false
+}
+
+// define records that the key of parent is defined at offset, by the named pair or section.
+// In strict mode, it reports a key which is already defined and returns false.
+func (s *scanner) define(parent *Node, key, name string, offset int, code ErrorCode) bool {
+	if !s.opts.Strict {
+		return true
+	}
+
+	// values merged from included files are not recorded, so they can be overridden
+	if prev, ok := s.defined[slot{parent, key}]; ok {
+		pe := s.errorAt(code, offset)
+		pe.Previous = &prev
+		s.addError(pe, name, offset)
+		return false
+	}
+
+	s.record(parent, key, offset)
+	return true
+}
+
+// record records where a key is first defined, in strict mode.
+func (s *scanner) record(parent *Node, key string, offset int) {
+	if !s.opts.Strict {
+		return
+	}
+	if s.defined == nil {
+		s.defined = make(map[slot]Position)
+	}
+	if _, ok := s.defined[slot{parent, key}]; !ok {
+		s.defined[slot{parent, key}] = s.position(offset)
+	}
+}
+
+// scanName scans the name of a pair and the delimiter after it.
+// It returns an empty string if there is no name before the end of the line.
 func (s *scanner) scanName() (name string) {
-	for i := s.offset; i < s.len; i++ {
+	i := s.offset
+	for ; i < s.len; i++ {
 		c := s.data[i]
-		if isSpace(c) || c == delimiter || c == ']' {
-			name = string(s.data[s.offset:i])
-			s.offset = i + 1
-			return
+		if isSpace(c) || c == delimiter {
+			break
 		} else if isLineEnd(c) {
 			return ""
 		}
 	}
 
-	return ""
+	if i == s.offset || i == s.len {
+		return ""
+	}
+
+	name = string(s.data[s.offset:i])
+	s.offset = i
+	s.skipSpace()
+	if s.offset < s.len && s.data[s.offset] == delimiter {
+		s.offset++
+	}
+	return
 }
 
 func (s *scanner) scanValue() (val interface{}, err error) {
-	//s.skip()
+	if s.offset >= s.len {
+		return nil, s.errorAt(ErrUnexpectedEOF, s.offset)
+	}
+
+	switch s.data[s.offset] {
+	case '[':
+		return s.scanArray()
+	case '{':
+		return s.scanObject()
+	case '&':
+		return s.scanAnchor()
+	case '*':
+		return s.scanAlias()
+	}
+	return s.scanScalar()
+}
+
+// scanScalar scans a value which is not an array or object.
+func (s *scanner) scanScalar() (val interface{}, err error) {
+	if s.offset >= s.len {
+		return nil, s.errorAt(ErrUnexpectedEOF, s.offset)
+	}
+
 	c := s.data[s.offset]
 	switch {
-	case c == '"':
-		return s.scanQuotedString()
-	case c == '`':
-		return s.scanRawString()
+	case c == '"' || c == '`':
+		start := s.offset
+		str, err := s.scanString()
+		if err == nil && s.opts.MaxStringLength > 0 && len(str) > s.opts.MaxStringLength {
+			return nil, s.errorAt(ErrStringTooLong, start)
+		}
+		if err == nil && !s.opts.DisableInterpolation && strings.Contains(str, "${") {
+			// resolved by finish, once the values it may refer to are scanned
+			return reference{s: str, pos: s.position(start), file: s.file}, nil
+		}
+		return str, err
 	case (c >= '0' && c <= '9') || c == '+' || c == '-':
+		start := s.offset
 		raw := s.scanRaw()
-
-		val, err = strconv.Atoi(raw)
-		if err == nil {
-			return
+		switch {
+		case isDatetime(raw):
+			return decodeDatetime(raw, s.opts.location())
+		case isDuration(raw):
+			return decodeDuration(raw)
 		}
 
-		val, err = strconv.ParseFloat(raw, 64)
-		if err == nil {
-			return
+		val, err = decodeNumber(raw)
+		if err != nil && isByteSize(raw) {
+			size, unitAt, e := decodeByteSize(raw)
+			if e != nil && unitAt > 0 {
+				return nil, s.errorAt(e.(*ParseError).Code, start+unitAt)
+			}
+			return size, e
 		}
-
-		return decodeDatetime(raw)
-
-	case c == '[':
-		val, err = s.scanArray()
+		return
 
 	case c == 't':
-		ret := s.scanExact([]byte{'r', 'u', 'e'})
-		if ret {
+		if s.scanExact("true") {
 			val = true
 		} else {
-			err = newError(invalidBoolValue)
+			err = newError(ErrInvalidBool)
 		}
 	case c == 'f':
-		ret := s.scanExact([]byte{'a', 'l', 's', 'e'})
-		if ret {
+		if s.scanExact("false") {
 			val = false
 		} else {
-			err = newError(invalidBoolValue)
+			err = newError(ErrInvalidBool)
+		}
+	case c == 'i':
+		if s.scanExact("inf") {
+			val = math.Inf(1)
+		} else {
+			err = newError(ErrInvalidNumber)
 		}
 	case c == 'n':
-		ret := s.scanExact([]byte{'u', 'l', 'l'})
-		if ret {
+		if s.scanExact("null") {
 			val = nil
+		} else if s.scanExact("nan") {
+			val = math.NaN()
 		} else {
-			err = newError(invalidNullValue)
+			err = newError(ErrInvalidNull)
 		}
-	case c == '{':
-		val, err = s.scanObject()
 	default:
-		err = newError(invalidValue)
+		err = newError(ErrInvalidValue)
 	}
 	return
 }
 
+// scanArray scans an array, like [1, 2, 3].
+// Arrays of a single type are returned as typed slices, others as []interface{}.
 func (s *scanner) scanArray() (val interface{}, err error) {
-	s.offset++
-	v, err := s.scanValue()
-	if err != nil {
-		return
+	start := s.offset
+	s.offset++ // skip '['
+	if err = s.enter(start); err != nil {
+		s.skipContainer()
+		return nil, err
 	}
+	defer s.leave()
 
-	switch v0 := v.(type) {
-	case string:
-		arr := []string{v0}
-
-		state := s.skipRestOfArrayItem()
-		for {
-			switch state {
-			case endOfItem:
-				s.skip()
-				v, err := s.scanString()
-				if err != nil {
-					goto ERR
-				} else {
-					arr = append(arr, v)
-					state = s.skipRestOfArrayItem()
-				}
-			case endOfArray:
-				return arr, nil
-			default:
-				return nil, newError(invalidArray)
+	items := []interface{}{}
+	for {
+		s.skip()
+		if s.offset >= s.len {
+			return nil, s.errorAt(ErrUnexpectedEOF, start)
+		}
+		if len(items) == 0 && s.data[s.offset] == ']' {
+			s.offset++
+			return items, nil
+		}
+
+		itemStart := s.offset
+		if s.opts.MaxArrayLength > 0 && len(items) == s.opts.MaxArrayLength {
+			err = s.errorAt(ErrArrayTooLong, itemStart)
+			s.skipContainer()
+			return nil, err
+		}
+		v, e := s.scanValue()
+		if e != nil {
+			if pe, ok := e.(*ParseError); ok && pe.Line == 0 {
+				pe.Position = s.position(itemStart)
 			}
+			s.skipContainer()
+			return nil, e
 		}
-	case int:
-		arr := []int{v0}
-
-		state := s.skipRestOfArrayItem()
-		for {
-			switch state {
-			case endOfItem:
-				raw := s.scanRaw()
-				v, err := strconv.Atoi(raw)
-				if err != nil {
-					goto ERR
-				} else {
-					arr = append(arr, v)
-					state = s.skipRestOfArrayItem()
-				}
-			case endOfArray:
-				return arr, nil
-			default:
-				return nil, newError(invalidArray)
+		items = append(items, v)
+
+		switch s.skipRestOfArrayItem() {
+		case endOfItem:
+		case endOfArray:
+			return typedArray(items), nil
+		case eof:
+			return nil, s.errorAt(ErrUnexpectedEOF, start)
+		default:
+			err = s.errorAt(ErrInvalidArray, s.offset)
+			s.skipContainer()
+			return nil, err
+		}
+	}
+}
+
+// typedArray converts the items of an array to a slice of their type.
+// Ints are promoted to floats in an array of numbers.
+// Empty arrays, nested arrays, arrays with null and arrays of mixed types stay []interface{}.
+func typedArray(items []interface{}) interface{} {
+	l := len(items)
+	if l == 0 {
+		return items
+	}
+
+	switch items[0].(type) {
+	case string:
+		arr := make([]string, l)
+		for i, v := range items {
+			if vt, ok := v.(string); ok {
+				arr[i] = vt
+			} else {
+				return items
 			}
 		}
+		return arr
+	case int, int64, uint64, float64:
+		return numberArray(items)
 	case bool:
-		arr := []bool{v0}
-
-		state := s.skipRestOfArrayItem()
-		for {
-			switch state {
-			case endOfItem:
-				v, err := s.scanBool()
-				if err != nil {
-					goto ERR
-				} else {
-					arr = append(arr, v)
-					state = s.skipRestOfArrayItem()
-				}
-			case endOfArray:
-				return arr, nil
-			default:
-				return nil, newError(invalidArray)
+		arr := make([]bool, l)
+		for i, v := range items {
+			if vt, ok := v.(bool); ok {
+				arr[i] = vt
+			} else {
+				return items
 			}
 		}
-	case float64:
-		arr := []float64{v0}
-
-		for state := s.skipRestOfArrayItem(); state == endOfItem; {
-			switch state {
-			case endOfItem:
-				raw := s.scanRaw()
-				v, err := strconv.ParseFloat(raw, 64)
-				if err != nil {
-					goto ERR
-				} else {
-					arr = append(arr, v)
-					state = s.skipRestOfArrayItem()
-				}
-			case endOfArray:
-				return arr, nil
-			default:
-				return nil, newError(invalidArray)
+		return arr
+	case ByteSize:
+		arr := make([]ByteSize, l)
+		for i, v := range items {
+			if vt, ok := v.(ByteSize); ok {
+				arr[i] = vt
+			} else {
+				return items
+			}
+		}
+		return arr
+	case time.Duration:
+		arr := make([]time.Duration, l)
+		for i, v := range items {
+			if vt, ok := v.(time.Duration); ok {
+				arr[i] = vt
+			} else {
+				return items
 			}
 		}
+		return arr
 	case time.Time:
-		arr := []time.Time{v0}
-
-		for state := s.skipRestOfArrayItem(); state == endOfItem; {
-			switch state {
-			case endOfItem:
-				raw := s.scanRaw()
-				v, err := decodeDatetime(raw)
-				if err != nil {
-					goto ERR
-				} else {
-					arr = append(arr, v)
-					state = s.skipRestOfArrayItem()
-				}
-			case endOfArray:
-				return arr, nil
-			default:
-				return nil, newError(invalidArray)
+		arr := make([]time.Time, l)
+		for i, v := range items {
+			if vt, ok := v.(time.Time); ok {
+				arr[i] = vt
+			} else {
+				return items
 			}
 		}
+		return arr
 	case *Node:
-		arr := []*Node{v0}
-		for state := s.skipRestOfArrayItem(); state == endOfItem; {
-			switch state {
-			case endOfItem:
-				v, err := s.scanObject()
-				if err != nil {
-					goto ERR
-				} else {
-					arr = append(arr, v)
-					state = s.skipRestOfArrayItem()
-				}
-			case endOfArray:
-				return arr, nil
-			default:
-				return nil, newError(invalidArray)
+		arr := make([]*Node, l)
+		for i, v := range items {
+			if vt, ok := v.(*Node); ok {
+				arr[i] = vt
+			} else {
+				return items
 			}
 		}
+		return arr
 	}
 
-ERR:
-	s.skipUntilChar(']')
-	s.offset++
+	return items
+}
 
-	return
+// numberArray converts numbers to []int, to []int64 or []uint64 if some of them are out of the range of int,
+// or to []float64 if any of them is a float.
+// Arrays that have both negative numbers and uint64 stay []interface{}.
+func numberArray(items []interface{}) interface{} {
+	ints := make([]int, len(items))
+	isFloat, isInt64, isUint64, negative := false, false, false, false
+	for i, v := range items {
+		switch vt := v.(type) {
+		case int:
+			ints[i] = vt
+			negative = negative || vt < 0
+		case int64:
+			isInt64 = true
+			negative = negative || vt < 0
+		case uint64:
+			isUint64 = true
+		case float64:
+			isFloat = true
+		default:
+			return items
+		}
+	}
+
+	switch {
+	case isFloat:
+		floats := make([]float64, len(items))
+		for i, v := range items {
+			switch vt := v.(type) {
+			case int:
+				floats[i] = float64(vt)
+			case int64:
+				floats[i] = float64(vt)
+			case uint64:
+				floats[i] = float64(vt)
+			case float64:
+				floats[i] = vt
+			}
+		}
+		return floats
+	case isUint64:
+		if negative {
+			return items
+		}
+		uints := make([]uint64, len(items))
+		for i, v := range items {
+			switch vt := v.(type) {
+			case int:
+				uints[i] = uint64(vt)
+			case int64:
+				uints[i] = uint64(vt)
+			case uint64:
+				uints[i] = vt
+			}
+		}
+		return uints
+	case isInt64:
+		int64s := make([]int64, len(items))
+		for i, v := range items {
+			if vt, ok := v.(int); ok {
+				int64s[i] = int64(vt)
+			} else {
+				int64s[i] = v.(int64)
+			}
+		}
+		return int64s
+	}
+	return ints
+}
+
+// enter enters an array or object which starts at offset.
+// It returns an error if the array or object is deeper than allowed.
+func (s *scanner) enter(offset int) error {
+	s.depth++
+	if s.opts.MaxDepth > 0 && s.depth > s.opts.MaxDepth {
+		s.depth--
+		return s.errorAt(ErrMaxDepth, offset)
+	}
+	return nil
+}
+
+func (s *scanner) leave() {
+	s.depth--
+}
+
+// skipContainer skips the rest of an invalid array or object, including the closing ']' or '}'.
+// Nested arrays and objects, strings and comments are skipped as a whole.
+func (s *scanner) skipContainer() {
+	depth := 1
+	for s.offset < s.len {
+		c := s.data[s.offset]
+		switch {
+		case c == '"' || c == '`':
+			s.skipString()
+			continue
+		case s.isComment():
+			s.skipUntil(isLineEnd)
+			continue
+		case c == '[' || c == '{':
+			depth++
+		case c == ']' || c == '}':
+			depth--
+			if depth == 0 {
+				s.offset++
+				return
+			}
+		}
+		s.offset++
+	}
+}
+
+// skipString skips a quoted or raw string without decoding it.
+func (s *scanner) skipString() {
+	quote := s.data[s.offset]
+	if quote == '"' && s.isTripleQuote(s.offset) {
+		for s.offset += 3; s.offset < s.len; s.offset++ {
+			if s.data[s.offset] == '\\' {
+				s.offset++
+			} else if s.isTripleQuote(s.offset) {
+				s.offset += 3
+				return
+			}
+		}
+		s.offset = s.len
+		return
+	}
+
+	for s.offset++; s.offset < s.len; s.offset++ {
+		c := s.data[s.offset]
+		if c == '\\' && quote == '"' {
+			s.offset++
+		} else if c == quote {
+			s.offset++
+			return
+		}
+	}
+	s.offset = s.len
 }
 
 func (s *scanner) scanRaw() (val string) {
@@ -309,31 +602,33 @@ func (s *scanner) scanRaw() (val string) {
 	for ; s.offset < s.len; s.offset++ {
 		c := s.data[s.offset]
 		if isLineEnd(c) || c == ',' || c == ']' || c == '}' || s.isComment() {
-			val = string(s.data[start:s.offset])
 			break
 		}
 	}
 
-	if s.offset == s.len {
-		val = string(s.data[start:])
-	}
-
-	return strings.TrimSpace(val)
+	return strings.TrimSpace(string(s.data[start:s.offset]))
 }
 
 func (s *scanner) scanString() (val string, err error) {
+	if s.offset >= s.len {
+		return "", s.errorAt(ErrUnexpectedEOF, s.offset)
+	}
+
 	c := s.data[s.offset]
-	switch c {
-	case '"':
+	switch {
+	case c == '"' && s.isTripleQuote(s.offset):
+		return s.scanMultilineString()
+	case c == '"':
 		return s.scanQuotedString()
-	case '`':
+	case c == '`':
 		return s.scanRawString()
 	default:
-		return "", newError(invalidStringValue)
+		return "", newError(ErrInvalidString)
 	}
 }
 
 func (s *scanner) scanQuotedString() (val string, err error) {
+	start := s.offset
 	s.offset++
 	var ret []byte
 	for i := s.offset; i < s.len; {
@@ -349,49 +644,15 @@ func (s *scanner) scanQuotedString() (val string, err error) {
 			return
 		case c == '\\':
 			//escape
-			//i++
-			if i == s.len-1 {
-				return "", newError(invalidEscape)
+			if i+1 >= s.len {
+				return "", s.errorAt(ErrUnexpectedEOF, start)
 			}
 			if ret == nil {
-				ret = s.data[s.offset:i]
+				ret = append([]byte{}, s.data[s.offset:i]...)
 			}
 
-			switch s.data[i+1] {
-			case '"', '\\', '/', '\'':
-				ret = append(ret, s.data[i])
-				i += 2
-			case 'b':
-				ret = append(ret, '\b')
-				i += 2
-			case 'f':
-				ret = append(ret, '\f')
-				i += 2
-			case 'n':
-				ret = append(ret, '\n')
-				i += 2
-			case 'r':
-				ret = append(ret, '\r')
-				i += 2
-			case 't':
-				ret = append(ret, '\t')
-				i += 2
-			case 'u':
-				i += 2
-				j := i + 4
-				if j >= s.len {
-					return "", newError(invalidUTF8StringValue)
-				}
-
-				ub, size, err := escapeU4(s.data[i:j])
-				if err != nil {
-					return "", err
-				}
-
-				ret = append(ret, ub[0:size]...)
-				i = j
-			default:
-				return "", newError(invalidEscape)
+			if ret, i, err = s.scanEscape(i, ret); err != nil {
+				return "", err
 			}
 		case c < utf8.RuneSelf:
 			// ASCII
@@ -403,100 +664,402 @@ func (s *scanner) scanQuotedString() (val string, err error) {
 			// Coerce to well-formed UTF-8.
 		default:
 			r, size := utf8.DecodeRune(s.data[i:])
-			if r == utf8.RuneError || size == 1 {
-				return "", newError(invalidUTF8StringValue)
-			} else {
-				j := i + size
-				if ret != nil {
-					ret = append(ret, s.data[i:j]...)
+			if r == utf8.RuneError && size == 1 {
+				return "", s.errorAt(ErrInvalidUTF8String, i)
+			}
+
+			j := i + size
+			if ret != nil {
+				ret = append(ret, s.data[i:j]...)
+			}
+			i = j
+		}
+	}
+
+	return "", s.errorAt(ErrUnexpectedEOF, start)
+}
+
+// scanEscape decodes the escape sequence at i, which is followed by at least one byte, and appends it to ret.
+// It returns the offset right after the sequence.
+func (s *scanner) scanEscape(i int, ret []byte) ([]byte, int, error) {
+	switch s.data[i+1] {
+	case '"', '\\', '/', '\'':
+		return append(ret, s.data[i+1]), i + 2, nil
+	case 'b':
+		return append(ret, '\b'), i + 2, nil
+	case 'f':
+		return append(ret, '\f'), i + 2, nil
+	case 'n':
+		return append(ret, '\n'), i + 2, nil
+	case 'r':
+		return append(ret, '\r'), i + 2, nil
+	case 't':
+		return append(ret, '\t'), i + 2, nil
+	case 'u':
+		j := i + 6
+		if j > s.len {
+			return ret, i, s.errorAt(ErrInvalidUTF8String, i)
+		}
+
+		ub, size, err := escapeU4(s.data[i+2 : j])
+		if err != nil {
+			return ret, i, s.errorAt(ErrInvalidUTF8String, i)
+		}
+		return append(ret, ub[0:size]...), j, nil
+	}
+	return ret, i, s.errorAt(ErrInvalidEscape, i)
+}
+
+// isTripleQuote reports whether a triple quote starts at i.
+func (s *scanner) isTripleQuote(i int) bool {
+	return i+3 <= s.len && s.data[i] == '"' && s.data[i+1] == '"' && s.data[i+2] == '"'
+}
+
+// scanMultilineString scans a string between triple quotes, like
+//
+//	query: """
+//	    SELECT *
+//	    FROM users
+//	    """
+//
+// The content starts on the line after the opening quotes. The indentation common to its lines
+// and to the closing quotes is removed, and the line end before the closing quotes is kept.
+// If the opening quotes are followed by '-', as in """-, the trailing line ends are trimmed.
+// Escapes are the same as in a quoted string.
+func (s *scanner) scanMultilineString() (val string, err error) {
+	start := s.offset
+	s.offset += 3
+	trim := s.offset < s.len && s.data[s.offset] == '-'
+	if trim {
+		s.offset++
+	}
+	s.skipSpace()
+	if s.offset < s.len && !isLineEnd(s.data[s.offset]) && !s.isComment() {
+		return "", s.errorAt(ErrInvalidString, s.offset)
+	}
+	s.skipRestOfLine()
+
+	end := -1
+	for i := s.offset; i < s.len; i++ {
+		if s.data[i] == '\\' {
+			i++
+		} else if s.isTripleQuote(i) {
+			end = i
+			break
+		}
+	}
+	if end < 0 {
+		return "", s.errorAt(ErrUnexpectedEOF, start)
+	}
+
+	// the lines of the content, the last one is followed by the closing quotes
+	var lines [][2]int
+	for i := s.offset; ; {
+		j := i
+		for j < end && s.data[j] != '\n' {
+			j++
+		}
+		if j == end {
+			lines = append(lines, [2]int{i, j})
+			break
+		}
+		e := j
+		if e > i && s.data[e-1] == '\r' {
+			e--
+		}
+		lines = append(lines, [2]int{i, e})
+		i = j + 1
+	}
+
+	// closing quotes on a line of their own end the content with a line end
+	last := lines[len(lines)-1]
+	closingLine := isBlank(s.data[last[0]:last[1]])
+
+	var indent []byte
+	for k, l := range lines {
+		line := s.data[l[0]:l[1]]
+		if isBlank(line) && !(closingLine && k == len(lines)-1) {
+			continue
+		}
+		lead := line[:len(line)-len(bytes.TrimLeft(line, " \t"))]
+		if indent == nil {
+			indent = lead
+		} else {
+			indent = commonPrefix(indent, lead)
+		}
+	}
+
+	if closingLine {
+		lines = lines[:len(lines)-1]
+	}
+
+	ret := []byte{}
+	for k, l := range lines {
+		if k > 0 {
+			ret = append(ret, '\n')
+		}
+
+		i := l[0]
+		for i < l[1] && i-l[0] < len(indent) && isSpace(s.data[i]) {
+			i++
+		}
+		for i < l[1] {
+			c := s.data[i]
+			if c == '\\' {
+				if ret, i, err = s.scanEscape(i, ret); err != nil {
+					return "", err
 				}
-				i = j
+				continue
 			}
+
+			r, size := utf8.DecodeRune(s.data[i:])
+			if r == utf8.RuneError && size == 1 {
+				return "", s.errorAt(ErrInvalidUTF8String, i)
+			}
+			ret = append(ret, s.data[i:i+size]...)
+			i += size
 		}
 	}
+	if closingLine && len(lines) > 0 {
+		ret = append(ret, '\n')
+	}
+
+	if trim {
+		ret = bytes.TrimRight(ret, "\r\n")
+	}
 
-	return "", newError(invalidStringValue)
+	s.offset = end + 3
+	return string(ret), nil
+}
+
+// isBlank reports whether b has only spaces.
+func isBlank(b []byte) bool {
+	return len(bytes.TrimLeft(b, " \t")) == 0
+}
+
+// commonPrefix returns the longest common prefix of a and b.
+func commonPrefix(a, b []byte) []byte {
+	i := 0
+	for i < len(a) && i < len(b) && a[i] == b[i] {
+		i++
+	}
+	return a[:i]
 }
 
 func (s *scanner) scanRawString() (val string, err error) {
+	start := s.offset
 	s.offset++
 	val, err = s.scanUntilChar('`')
 	if err == nil {
 		s.offset++
 	} else {
-		err = newError(invalidStringValue)
+		err = s.errorAt(ErrUnexpectedEOF, start)
 	}
 
 	return
 }
 
-func (s *scanner) scanBool() (val bool, err error) {
-	raw := s.scanRaw()
-	if raw == "true" {
-		val = true
-	} else if raw != "false" {
-		err = newError(invalidBoolValue)
-	}
-	return
-}
-
 func (s *scanner) scanObject() (val *Node, err error) {
+	start := s.offset
 	s.offset++ //skip '{'
+	if err = s.enter(start); err != nil {
+		s.skipContainer()
+		return nil, err
+	}
+	defer s.leave()
 	val = NewNode()
 
-	for s.offset < s.len-1 {
+	for {
 		s.skip()
+		if s.offset >= s.len {
+			return val, s.errorAt(ErrUnexpectedEOF, start)
+		}
 		if s.data[s.offset] == '}' {
+			s.offset++
 			return
 		}
 		s.scanPair(val)
 	}
-
-	return val, newError(invalidObject)
 }
 
 func (s *scanner) scanNode(parent *Node) {
-	s.offset++
-	name := s.scanName()
-	if name == "" {
-		s.addErrorMsg(invalidNodeName)
+	start := s.offset
+	name, base, err := s.scanNodeName()
+	if err != nil {
+		s.addError(err, name, start)
 		s.skipRestOfLine()
 		return
 	}
 
+	s.skipRestOfLine()
 	s.skip()
 
-	if s.data[s.offset] == '-' {
-		parent.dict[name] = s.scanNodeList()
-	} else {
-		parent.dict[name] = s.scanSingleNode()
+	key := name
+	if strings.Contains(name, ".") {
+		parent, key, err = s.nodeParent(parent, name, start)
+		if err != nil {
+			s.addError(err, name, start)
+			// the section is scanned, but not kept
+			parent = NewNode()
+		}
+	}
+
+	isList := s.offset < s.len && s.data[s.offset] == '-'
+	if isList && base != "" {
+		// only a single node can inherit, the list is scanned without its base
+		s.addError(newError(ErrInvalidNodeList), name, start)
+		base = ""
 	}
+
+	switch existing := parent.dict[key].(type) {
+	case *Node:
+		if !isList && (s.implicit[existing] || s.opts.MergeSections) {
+			// a node created by a dotted name or an include is extended by its own section,
+			// which is its first definition
+			if s.implicit[existing] {
+				delete(s.implicit, existing)
+				delete(s.defined, slot{parent, key})
+			}
+			s.record(parent, key, start)
+			if base != "" {
+				s.addInheritance(existing, name, base, start)
+			}
+			s.scanSingleNode(existing)
+			return
+		}
+	case []*Node:
+		if isList && s.opts.MergeSections {
+			parent.set(key, append(existing, s.scanNodeList()...))
+			return
+		}
+	}
+
+	if !s.countKey(name, start) || !s.define(parent, key, name, start, ErrDuplicateSection) {
+		parent = NewNode()
+	}
+	if isList {
+		parent.set(key, s.scanNodeList())
+		return
+	}
+
+	node := NewNode()
+	if base != "" {
+		s.addInheritance(node, name, base, start)
+	}
+	parent.set(key, s.scanSingleNode(node))
+}
+
+// nodeParent finds the node that a section with a dotted name, like [server.tls], belongs to.
+// Missing nodes on the way are created. It returns the parent and the last part of the name.
+func (s *scanner) nodeParent(parent *Node, name string, offset int) (*Node, string, error) {
+	parts := strings.Split(name, ".")
+	if s.opts.MaxDepth > 0 && len(parts) > s.opts.MaxDepth {
+		return nil, "", s.errorAt(ErrMaxDepth, offset)
+	}
+	for _, p := range parts[:len(parts)-1] {
+		v, ok := parent.dict[p]
+		switch vt := v.(type) {
+		case *Node:
+			parent = vt
+			continue
+		case nil:
+			if !ok {
+				n := NewNode()
+				parent.set(p, n)
+				s.record(parent, p, offset)
+				s.markImplicit(n)
+				parent = n
+				continue
+			}
+		}
+		return nil, "", newError(ErrNodeConflict)
+	}
+	return parent, parts[len(parts)-1], nil
+}
+
+// scanNodeName scans the [name] header of a node.
+// The name may have dots between its parts, like [server.tls],
+// and may be followed by the name of the section it inherits from, like [prod : base].
+func (s *scanner) scanNodeName() (name, base string, err error) {
+	start := s.offset
+	end := s.findPosOf(']')
+	if end > 0 {
+		name = strings.TrimSpace(string(s.data[start+1 : end]))
+	}
+	if i := strings.IndexByte(name, delimiter); i >= 0 {
+		name, base = strings.TrimSpace(name[:i]), strings.TrimSpace(name[i+1:])
+		if !isNodeName(base) {
+			return name, base, s.errorAt(ErrInvalidNodeName, start)
+		}
+	}
+	if !isNodeName(name) {
+		return name, base, s.errorAt(ErrInvalidNodeName, start)
+	}
+
+	s.offset = end + 1
+	return
+}
+
+// isKeyName reports whether name can be written as a key and read back by scanName: it must not hold spaces,
+// line ends or ':', which end a name, nor '[', ']' or comment marks.
+func isKeyName(name string) bool {
+	return name != "" && !strings.ContainsAny(name, " \t\r\n:[]#") && !strings.Contains(name, "//")
+}
+
+func isNodeName(name string) bool {
+	return name != "" && !strings.ContainsAny(name, " \t") &&
+		!strings.HasPrefix(name, ".") && !strings.HasSuffix(name, ".") && !strings.Contains(name, "..")
 }
 
 func (s *scanner) scanLine(parent *Node) {
 	s.skipSpace()
-	s.scanPair(parent)
+	if s.offset < s.len && s.data[s.offset] == '@' {
+		s.scanDirective(parent)
+	} else {
+		s.scanPair(parent)
+	}
 	s.skipRestOfLine()
 }
 
-func (s *scanner) scanSingleNode() (node *Node) {
-	node = NewNode()
+// scanSingleNode scans the pairs of a section into node.
+func (s *scanner) scanSingleNode(node *Node) *Node {
 	for !s.isBlankLine() {
 		s.scanLine(node)
 	}
 
-	return
+	return node
 }
 
 func (s *scanner) scanNodeList() (list []*Node) {
 	list = []*Node{}
 
 	var node *Node
+	tooLong := false
 	for !s.isBlankLine() {
+		s.skipSpace()
 		if s.data[s.offset] == '-' {
 			node = NewNode()
-			list = append(list, node)
+			if s.opts.MaxArrayLength > 0 && len(list) == s.opts.MaxArrayLength {
+				// the nodes over the limit are scanned, but not kept
+				if !tooLong {
+					s.addError(s.errorAt(ErrArrayTooLong, s.offset), "", s.offset)
+					tooLong = true
+				}
+			} else {
+				list = append(list, node)
+			}
 			s.offset++
+			s.skipSpace()
+			if s.offset >= s.len || isLineEnd(s.data[s.offset]) || s.isComment() {
+				s.skipRestOfLine()
+				continue
+			}
+		}
+
+		if node == nil {
+			s.addError(newError(ErrInvalidNodeList), "", s.offset)
+			s.skipRestOfLine()
+			continue
 		}
 		s.scanLine(node)
 	}
@@ -505,7 +1068,7 @@ func (s *scanner) scanNodeList() (list []*Node) {
 
 // skip to next meaningful byte
 func (s *scanner) skip() {
-	for s.offset < s.len-1 {
+	for s.offset < s.len {
 		c := s.data[s.offset]
 		if s.isComment() || isLineEnd(c) {
 			s.skipRestOfLine()
@@ -515,25 +1078,13 @@ func (s *scanner) skip() {
 			return
 		}
 	}
-	/*for i := s.offset; i < s.len; {
-		if s.isComment() || isLineEnd(s.data[i]) {
-			s.skipRestOfLine()
-			i = s.offset
-		} else if isSpace(s.data[i]) {
-			s.offset++
-			i++
-		} else {
-			return
-		}
-	}*/
 }
 
 func (s *scanner) skipLineEnd() {
-	if s.offset >= s.len-1 {
+	if s.offset >= s.len {
 		return
 	}
 
-	s.line++
 	if s.data[s.offset] == '\r' {
 		i := s.offset + 1
 		if i < s.len && s.data[i] == '\n' {
@@ -550,32 +1101,25 @@ func (s *scanner) skipRestOfLine() {
 }
 
 func (s *scanner) skipSpace() {
-	for i := s.offset; i < s.len; i++ {
-		if isSpace(s.data[i]) {
-			s.offset++
-		} else {
-			return
-		}
+	for s.offset < s.len && isSpace(s.data[s.offset]) {
+		s.offset++
 	}
 }
 
 func (s *scanner) skipRestOfArrayItem() scanState {
-	for i := s.offset; i < s.len; {
-		c := s.data[i]
+	for s.offset < s.len {
+		c := s.data[s.offset]
 		switch {
 		case c == ',':
-			s.offset = i + 1
+			s.offset++
 			return endOfItem
 		case isSpace(c):
-			i++
-			// continue
+			s.offset++
 		case c == ']':
-			s.offset = i + 1
+			s.offset++
 			return endOfArray
 		case s.isComment() || isLineEnd(c):
 			s.skipRestOfLine()
-			i = s.offset
-			// continue
 		default:
 			return scanError
 		}
@@ -584,7 +1128,7 @@ func (s *scanner) skipRestOfArrayItem() scanState {
 }
 
 func (s *scanner) skipUntil(fn func(byte) bool) {
-	for s.offset < s.len-1 {
+	for s.offset < s.len {
 		if fn(s.data[s.offset]) {
 			return
 		}
@@ -599,13 +1143,18 @@ func (s *scanner) skipUntilChar(c byte) {
 			return
 		}
 	}
+	s.offset = s.len
 }
 
+// findPosOf finds the position of c in the rest of the current line, or -1 if there is none.
 func (s *scanner) findPosOf(c byte) int {
 	for i := s.offset; i < s.len; i++ {
 		if s.data[i] == c {
 			return i
 		}
+		if isLineEnd(s.data[i]) {
+			break
+		}
 	}
 	return -1
 }
@@ -619,62 +1168,43 @@ func (s *scanner) scanUntilChar(c byte) (v string, err error) {
 		}
 	}
 
-	return "", newError(invalidValue)
+	return "", newError(ErrInvalidValue)
 }
 
-func (s *scanner) scanLineUntilChar(c byte) (v string, err error) {
-	for i := s.offset; i < s.len; i++ {
-		if s.data[i] == c {
-			v = string(s.data[s.offset:i])
-			s.offset = i
-			return
-		}
-
-		if isLineEnd(s.data[i]) {
-			goto ERR
-		}
+// scanExact scans the exact word, which must not be followed by other characters of a value.
+func (s *scanner) scanExact(word string) bool {
+	end := s.offset + len(word)
+	if end > s.len || string(s.data[s.offset:end]) != word {
+		return false
 	}
 
-ERR:
-	err = errors.New(invalidValue)
-	return
-}
-
-func (s *scanner) scanExact(expect []byte) bool {
-	l := len(expect)
-	for i := 0; i < l; i++ {
-		if s.offset+i > s.len {
-			return false
-		}
-		if s.data[i] != s.data[s.offset+i+1] {
+	if end < s.len {
+		c := s.data[end]
+		if !isSpace(c) && !isLineEnd(c) && c != ',' && c != ']' && c != '}' && !s.isCommentAt(end) {
 			return false
 		}
 	}
 
-	s.offset += l + 1
+	s.offset = end
 	return true
 }
 
 // RJ support both '#' and `//` to start a comment
-func isComment(a, b byte) bool {
-	if a == '#' {
-		return true
-	}
+func (s *scanner) isComment() bool {
+	return s.isCommentAt(s.offset)
+}
 
-	if a == '/' && b == '/' {
-		return true
+func (s *scanner) isCommentAt(i int) bool {
+	if i >= s.len {
+		return false
 	}
 
-	return false
-}
-
-func (s *scanner) isComment() bool {
-	if s.data[s.offset] == '#' {
-		return true
+	if s.data[i] == '#' {
+		return s.opts.allowsComment(CommentHash)
 	}
 
-	if s.data[s.offset] == '/' {
-		next := s.offset + 1
+	if s.data[i] == '/' && s.opts.allowsComment(CommentSlash) {
+		next := i + 1
 		if next < s.len && s.data[next] == '/' {
 			return true
 		}
@@ -683,12 +1213,13 @@ func (s *scanner) isComment() bool {
 	return false
 }
 
-//A node end by blank lines.
-//A blank line means it contains only spaces or comments
+// A node end by blank lines.
+// A blank line means it contains only spaces or comments
 func (s *scanner) isBlankLine() bool {
 	for i := s.offset; i < s.len; i++ {
 		c := s.data[i]
-		if s.isComment() || isLineEnd(c) {
+		if s.isCommentAt(i) || isLineEnd(c) {
+			s.offset = i
 			s.skipRestOfLine()
 			return true
 		}
@@ -697,6 +1228,8 @@ func (s *scanner) isBlankLine() bool {
 			return false
 		}
 	}
+
+	s.offset = s.len
 	return true
 }
 
@@ -1,6 +1,8 @@
 package rj
 
 import (
+	"errors"
+	"math"
 	"reflect"
 	"testing"
 	"time"
@@ -225,6 +227,33 @@ func TestScanQuotedString(t *testing.T) {
 		}
 	}
 }
+
+func TestScanMultilineString(t *testing.T) {
+	cases := []testCase{
+		{input: "\"\"\"\n    a\n      b\n    \"\"\"", expected: "a\n  b\n"},
+		{input: "\"\"\"\n  a\n\n  b\"\"\"", expected: "a\n\nb"},
+		{input: "\"\"\"-\n  a\n  b\n\n  \"\"\"", expected: "a\nb"},
+		{input: "\"\"\" # comment\r\n\ta\r\n\t\"\"\"", expected: "a\n"},
+		{input: "\"\"\"\n  \\t\\\"\"\"\\u6C49\n  \"\"\"", expected: "\t\"\"\"汉\n"},
+		{input: "\"\"\"\n\"\"\"", expected: ""},
+		{input: "\"\"\"\n  a\n  \"\"", err: true},
+		{input: "\"\"\" a\n\"\"\"", err: true},
+		{input: "\"\"\"\n  \\x\n\"\"\"", err: true},
+	}
+
+	for _, tc := range cases {
+		sc := newScanner([]byte(tc.input))
+		ret, err := sc.scanScalar()
+		if tc.err {
+			if err == nil {
+				t.Error("ScanMultilineString failed, input:", tc.input, "expected error")
+			}
+		} else if err != nil || ret != tc.expected.(string) || sc.offset != sc.len {
+			t.Errorf("ScanMultilineString failed, input: %q, expected: %q, actual: %q, %v", tc.input, tc.expected, ret, err)
+		}
+	}
+}
+
 func TestScanPair(t *testing.T) {
 	cases := []testCase{
 		/*{input: `name: "str \n value"`, expected: map[string]interface{}{"name": "str \n value"}},
@@ -343,9 +372,252 @@ func TestScanNode(t *testing.T) {
 
 }
 
+func TestScanDottedNode(t *testing.T) {
+	in := `[server.tls]
+cert: "a.pem"
+
+[server]
+host: "localhost"
+
+[server.tls.client]
+verify: true
+
+[server.backends]
+- host: "b1"
+
+[name.first]
+x: 1
+`
+	sc := newScanner([]byte("name: \"Zoe\"\n" + in))
+	sc.scan()
+
+	root := sc.root
+	backends, _ := root.GetNodeList("server.backends")
+	if root.GetString("server.tls.cert") != "a.pem" || root.GetString("server.host") != "localhost" ||
+		!root.GetBool("server.tls.client.verify") || len(backends) != 1 {
+		t.Error("Scan dotted node failed, got:", root.dict)
+	}
+	server, _ := root.GetNode("server")
+	if keys := server.Keys(); !arrayEquals(keys, []string{"tls", "host", "backends"}) {
+		t.Error("Scan dotted node failed, expected keys: [tls host backends], got:", keys)
+	}
+
+	if sc.error == nil || len(sc.error.Errors) != 1 || sc.error.Errors[0].Code != ErrNodeConflict ||
+		sc.error.Errors[0].Name != "name.first" || sc.error.Errors[0].Line != 14 {
+		t.Error("Scan dotted node failed, expected a conflict at line 14, got:", sc.error)
+	}
+	if root.GetString("name") != "Zoe" || root.GetInt("x") != 0 {
+		t.Error("Scan dotted node failed, expected the conflicting section to be dropped, got:", root.dict)
+	}
+
+	for _, name := range []string{"[.a]", "[a.]", "[a..b]"} {
+		sc = newScanner([]byte(name))
+		if _, _, err := sc.scanNodeName(); err == nil {
+			t.Error("ScanNodeName failed, input:", name, ", expected error")
+		}
+	}
+}
+
 func TestScan(t *testing.T) {
 	in := `name: "a\b"
 array: ["a","b"]`
 	s := newScanner([]byte(in))
 	s.scan()
 }
+
+func TestScanArray(t *testing.T) {
+	cases := []testCase{
+		{input: `[1, 2]`, expected: []int{1, 2}},
+		{input: `[1, 2.5]`, expected: []float64{1, 2.5}},
+		{input: `["a", ` + "`b`" + `]`, expected: []string{"a", "b"}},
+		{input: `[]`, expected: []interface{}{}},
+		{input: `[ # empty
+		]`, expected: []interface{}{}},
+		{input: `[1, null]`, expected: []interface{}{1, nil}},
+		{input: `[1, "a", true]`, expected: []interface{}{1, "a", true}},
+		{input: `[[1, 2], [3.5], []]`, expected: []interface{}{[]int{1, 2}, []float64{3.5}, []interface{}{}}},
+		{input: `[1, 9223372036854775808]`, expected: []uint64{1, 9223372036854775808}},
+		{input: `[-1, 9223372036854775808]`, expected: []interface{}{-1, uint64(9223372036854775808)}},
+		{input: `[1, 0x10, 2.5]`, expected: []float64{1, 16, 2.5}},
+		{input: `[1, 2,]`, err: true},
+		{input: `[[1, "a\c"], 2`, err: true},
+	}
+
+	for _, tc := range cases {
+		sc := newScanner([]byte(tc.input))
+		val, err := sc.scanArray()
+		if tc.err {
+			if err == nil {
+				t.Error("ScanArray failed, input:", tc.input, ", expected error")
+			}
+		} else if err != nil || !reflect.DeepEqual(val, tc.expected) {
+			t.Errorf("ScanArray failed, input: %s, expected: %#v, got: %#v, %v", tc.input, tc.expected, val, err)
+		}
+	}
+}
+
+func TestScanNumber(t *testing.T) {
+	cases := []testCase{
+		{input: `123`, expected: 123},
+		{input: `-0x_FF`, expected: -255},
+		{input: `0o755`, expected: 493},
+		{input: `0b1010`, expected: 10},
+		{input: `1_000_000`, expected: 1000000},
+		{input: `0755`, expected: 755},
+		{input: `9223372036854775807`, expected: int(math.MaxInt64)},
+		{input: `18446744073709551615`, expected: uint64(math.MaxUint64)},
+		{input: `1_000.5`, expected: 1000.5},
+		{input: `-1.5e3`, expected: -1500.0},
+		{input: `123456789012345678901.5`, expected: 123456789012345678901.5},
+		{input: `-99999999999999999999e2`, expected: -99999999999999999999e2},
+		{input: `inf`, expected: math.Inf(1)},
+		{input: `-inf`, expected: math.Inf(-1)},
+		{input: `18446744073709551616`, err: true},
+		{input: `-9223372036854775809`, err: true},
+		{input: `1e400`, err: true},
+		{input: `1__0`, err: true},
+		{input: `0xZZ`, err: true},
+		{input: `infinite`, err: true},
+	}
+
+	for _, tc := range cases {
+		sc := newScanner([]byte(tc.input))
+		val, err := sc.scanScalar()
+		if tc.err {
+			if err == nil {
+				t.Error("ScanNumber failed, input:", tc.input, ", expected error")
+			}
+		} else if err != nil || val != tc.expected {
+			t.Errorf("ScanNumber failed, input: %s, expected: %#v, got: %#v, %v", tc.input, tc.expected, val, err)
+		}
+	}
+
+	sc := newScanner([]byte("nan"))
+	if val, err := sc.scanScalar(); err != nil || !math.IsNaN(val.(float64)) {
+		t.Error("ScanNumber failed, input: nan, got:", val, err)
+	}
+
+	node, err := ParseString("big: 99999999999999999999\nday: 2019-10-11\n")
+	if !errors.Is(err, ErrNumberOutOfRange) || errors.Is(err, ErrInvalidTime) || node.GetTime("day").Day() != 11 {
+		t.Error("Parse out of range number failed, expected error (number out of range), got:", err)
+	}
+}
+
+func TestScanDuration(t *testing.T) {
+	cases := []testCase{
+		{input: `30s`, expected: 30 * time.Second},
+		{input: `1h30m`, expected: 90 * time.Minute},
+		{input: `250ms`, expected: 250 * time.Millisecond},
+		{input: `-1.5h`, expected: -90 * time.Minute},
+		{input: `2µs`, expected: 2 * time.Microsecond},
+		{input: `9999999999h`, err: true},
+		{input: `1h30`, err: true},
+	}
+
+	for _, tc := range cases {
+		sc := newScanner([]byte(tc.input))
+		val, err := sc.scanScalar()
+		if tc.err {
+			if err == nil {
+				t.Error("ScanDuration failed, input:", tc.input, ", expected error")
+			}
+		} else if err != nil || val != tc.expected {
+			t.Error("ScanDuration failed, input:", tc.input, ", expected:", tc.expected, ", got:", val, err)
+		}
+	}
+
+	sc := newScanner([]byte(`[1s, 2m]`))
+	if val, err := sc.scanArray(); err != nil || !reflect.DeepEqual(val, []time.Duration{time.Second, 2 * time.Minute}) {
+		t.Error("ScanDuration failed, expected an array of durations, got:", val, err)
+	}
+}
+
+func TestScanArrayRecovery(t *testing.T) {
+	in := `bad: [[1, "]"], x,
+	"a\c"]
+good: [1]
+`
+	sc := newScanner([]byte(in))
+	sc.scan()
+	if sc.error == nil || len(sc.error.Errors) != 1 {
+		t.Error("Scan failed, expected one error, got:", sc.error)
+	}
+	if !arrayEquals(sc.root.GetIntArray("good"), []int{1}) {
+		t.Error("Scan failed, expected the pair after the invalid array, got:", sc.root.dict)
+	}
+}
+
+func TestScanInheritance(t *testing.T) {
+	in := `[prod : base]
+host: "prod.local"
+db: {user: "prod"}
+
+[base]
+host: "localhost"
+port: 8080
+db: {user: "dev"
+	pool: 10}
+url: "${base.host}"
+
+[env.staging:prod]
+port: 9090
+
+[loop.a : loop.b]
+x: 1
+
+[loop.b : loop.a]
+x: 2
+
+[orphan : missing]
+x: 1
+
+[list : base]
+- a: 1
+`
+	s := newScanner([]byte(in))
+	s.scan()
+	s.finish()
+
+	prod, _ := s.root.GetNode("prod")
+	if prod.GetString("host") != "prod.local" || prod.GetInt("port") != 8080 ||
+		prod.GetString("db.user") != "prod" || prod.GetInt("db.pool") != 10 {
+		t.Error("Scan inheritance failed, got:", prod.dict)
+	}
+	if keys := prod.Keys(); !arrayEquals(keys, []string{"host", "port", "db", "url"}) {
+		t.Error("Scan inheritance failed, expected keys: [host port db url], got:", keys)
+	}
+	if s.root.GetString("env.staging.host") != "prod.local" || s.root.GetInt("env.staging.port") != 9090 {
+		t.Error("Scan inheritance failed, expected a chain of bases, got:", s.root.dict["env"])
+	}
+	if s.root.GetString("base.db.user") != "dev" || s.root.GetInt("base.port") != 8080 {
+		t.Error("Scan inheritance failed, expected the base to be unchanged, got:", s.root.dict["base"])
+	}
+	if s.root.GetInt("orphan.x") != 1 {
+		t.Error("Scan inheritance failed, expected the own pairs of an orphan, got:", s.root.dict["orphan"])
+	}
+
+	if s.error == nil || len(s.error.Errors) != 3 {
+		t.Fatal("Scan inheritance failed, expected three errors, got:", s.error)
+	}
+	expected := []struct {
+		name string
+		code ErrorCode
+		line int
+	}{
+		{"list", ErrInvalidNodeList, 24},
+		{"loop.b", ErrInheritanceCycle, 18},
+		{"orphan", ErrUnknownParent, 21},
+	}
+	for i, e := range expected {
+		if pe := s.error.Errors[i]; pe.Name != e.name || pe.Code != e.code || pe.Line != e.line {
+			t.Error("Scan inheritance failed, expected error:", e, ", got:", pe)
+		}
+	}
+
+	for _, name := range []string{"[a : ]", "[a : .b]", "[ : b]"} {
+		sc := newScanner([]byte(name))
+		if _, _, err := sc.scanNodeName(); err == nil {
+			t.Error("ScanNodeName failed, input:", name, ", expected error")
+		}
+	}
+}
@@ -0,0 +1,226 @@
+package rj
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"reflect"
+)
+
+// A Decoder reads and decodes an RJ document from an input stream.
+//
+// The input is read line by line and scanned one top level block at a time:
+// a pair, or a section up to the blank line that ends it.
+// Only the block being scanned is held in memory, besides the decoded node.
+type Decoder struct {
+	r        *bufio.Reader
+	scanner  *scanner
+	splitter blockSplitter
+	buf      []byte
+	done     bool
+}
+
+// NewDecoder returns a new decoder that reads from r.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: bufio.NewReader(r), scanner: newScanner(nil)}
+}
+
+// SetOptions sets the options of parsing, it must be called before decoding.
+func (d *Decoder) SetOptions(opts ParseOptions) {
+	d.scanner.opts = opts
+	d.splitter.opts = opts
+	if opts.MaxDocumentSize > 0 {
+		// one byte over the limit is enough for the scanner to report the document as too large
+		d.r = bufio.NewReader(io.LimitReader(d.r, int64(opts.MaxDocumentSize)+1))
+	}
+}
+
+// DecodeNode reads the whole RJ document from its input and returns it as a node.
+// It returns io.EOF if the document has already been decoded.
+func (d *Decoder) DecodeNode() (node *Node, err error) {
+	if d.done {
+		return nil, io.EOF
+	}
+	d.done = true
+
+	for {
+		line, rerr := d.r.ReadBytes('\n')
+		if len(line) > 0 {
+			d.buf = append(d.buf, line...)
+			if d.splitter.line(line) {
+				d.scanBlock()
+			}
+		}
+
+		if rerr == io.EOF {
+			break
+		}
+		if rerr != nil {
+			return nil, rerr
+		}
+	}
+	d.scanBlock()
+	d.scanner.finish()
+
+	node = d.scanner.root
+	if d.scanner.error != nil && len(d.scanner.error.Errors) > 0 {
+		err = d.scanner.error
+	}
+	return
+}
+
+// Decode reads the whole RJ document from its input and stores it in the struct pointed to by v.
+func (d *Decoder) Decode(v interface{}) error {
+	node, err := d.DecodeNode()
+	if err != nil {
+		return err
+	}
+
+	return decode(node, v)
+}
+
+func (d *Decoder) scanBlock() {
+	if len(d.buf) == 0 {
+		return
+	}
+
+	d.scanner.feed(d.buf)
+	d.scanner.scan()
+	d.buf = nil
+}
+
+// blockSplitter finds the lines of an RJ document after which a top level block is complete,
+// so the document can be scanned block by block.
+type blockSplitter struct {
+	depth       int  // depth of open arrays and objects
+	quote       byte // the quote of an open string, or 0
+	triple      bool // whether the open string is between triple quotes
+	inSection   bool
+	sectionBody bool // whether the current section has any content yet
+	opts        ParseOptions
+}
+
+// line processes the next line of the input, including its line end.
+// It returns true if a block ends with the line.
+func (b *blockSplitter) line(l []byte) bool {
+	content := bytes.TrimSpace(l)
+	if b.depth == 0 && b.quote == 0 && len(content) > 0 && content[0] == '[' {
+		// a section header
+		b.inSection, b.sectionBody = true, false
+		return false
+	}
+
+	blank := b.depth == 0 && b.quote == 0 && b.isBlank(content)
+	b.scanLine(l)
+	if b.depth > 0 || b.quote != 0 {
+		return false
+	}
+
+	if !b.inSection {
+		return true
+	}
+
+	if !blank {
+		b.sectionBody = true
+		return false
+	}
+
+	// blank lines right after a header do not end the section
+	if b.sectionBody {
+		b.inSection = false
+		return true
+	}
+	return false
+}
+
+// scanLine tracks strings, arrays and objects which continue on the next line.
+func (b *blockSplitter) scanLine(l []byte) {
+	for i := 0; i < len(l); i++ {
+		c := l[i]
+		if b.quote != 0 {
+			if c == '\\' && b.quote == '"' {
+				i++
+			} else if b.triple && bytes.HasPrefix(l[i:], tripleQuote) {
+				b.quote, b.triple = 0, false
+				i += 2
+			} else if c == b.quote && !b.triple {
+				b.quote = 0
+			}
+			continue
+		}
+
+		switch c {
+		case '"', '`':
+			b.quote = c
+			if c == '"' && bytes.HasPrefix(l[i:], tripleQuote) {
+				b.triple = true
+				i += 2
+			}
+		case '[', '{':
+			b.depth++
+		case ']', '}':
+			if b.depth > 0 {
+				b.depth--
+			}
+		case '#':
+			if b.opts.allowsComment(CommentHash) {
+				return
+			}
+		case '/':
+			if i+1 < len(l) && l[i+1] == '/' && b.opts.allowsComment(CommentSlash) {
+				return
+			}
+		}
+	}
+}
+
+var tripleQuote = []byte(`"""`)
+
+// isBlank reports whether a trimmed line is empty or a comment.
+func (b *blockSplitter) isBlank(content []byte) bool {
+	if len(content) == 0 {
+		return true
+	}
+	if content[0] == '#' {
+		return b.opts.allowsComment(CommentHash)
+	}
+	return bytes.HasPrefix(content, []byte("//")) && b.opts.allowsComment(CommentSlash)
+}
+
+// An Encoder writes RJ values to an output stream.
+//
+// The output is written through a small buffer, so the encoding of a value is never held in memory as a whole.
+// As a consequence, part of the output may have been written when Encode returns an error.
+type Encoder struct {
+	out     io.Writer
+	w       *bufio.Writer
+	anchors bool
+}
+
+// NewEncoder returns a new encoder that writes to w.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{out: w, w: bufio.NewWriter(w)}
+}
+
+// SetAnchors sets whether a value which is found more than once through the same pointer,
+// like a *Node in two places, is written once with an anchor (&a1) and then as aliases of it (*a1).
+// Otherwise it is written in full each time.
+func (enc *Encoder) SetAnchors(on bool) {
+	enc.anchors = on
+}
+
+// Encode writes the RJ encoding of v to the stream.
+// A struct is written as a list of pairs, other values are written as a single value.
+func (enc *Encoder) Encode(v interface{}) error {
+	e := newEncoder(enc.w)
+	if enc.anchors {
+		e.refs, e.anchors = make(map[pointer]int), make(map[pointer]string)
+		e.countRefs(reflect.ValueOf(v))
+	}
+	if err := e.encode(v); err != nil {
+		// drop what is still buffered of the invalid value
+		enc.w.Reset(enc.out)
+		return err
+	}
+	return enc.w.Flush()
+}
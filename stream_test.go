@@ -0,0 +1,137 @@
+package rj
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+const streamTestInput = `name: "Zoe" # comment
+scores: [1,
+	2, 3]
+raw: ` + "`a\n\nb`" + `
+
+[Server]
+
+host: "localhost"
+port: 80
+
+[Clients]
+- name: "a"
+- name: "b"
+`
+
+type errReader struct {
+	err error
+}
+
+func (r errReader) Read(p []byte) (int, error) {
+	return 0, r.err
+}
+
+func TestDecoder_DecodeNode(t *testing.T) {
+	d := NewDecoder(iotest.OneByteReader(strings.NewReader(streamTestInput)))
+	node, err := d.DecodeNode()
+	if err != nil {
+		t.Fatal("DecodeNode failed, expected no error, got:", err)
+	}
+
+	// compare the nodes deeply, sections and node lists included
+	expected, _ := ParseString(streamTestInput)
+	if !reflect.DeepEqual(node, expected) {
+		t.Error("DecodeNode failed, expected:", expected.dict, ", got:", node.dict)
+	}
+
+	server, err := node.GetNode("Server")
+	if err != nil || server.GetInt("port") != 80 {
+		t.Error("DecodeNode failed, expected section Server with port 80, got:", server, err)
+	}
+
+	list, err := node.GetNodeList("Clients")
+	if err != nil || len(list) != 2 {
+		t.Error("DecodeNode failed, expected node list Clients of 2 nodes, got:", list, err)
+	}
+
+	_, err = d.DecodeNode()
+	if err != io.EOF {
+		t.Error("DecodeNode failed, expected io.EOF after the document, got:", err)
+	}
+}
+
+func TestDecoder_Decode(t *testing.T) {
+	type ts struct {
+		Name string
+		Age  int
+	}
+	in := `Name: "abc"
+
+Age: 12
+`
+	v := new(ts)
+	err := NewDecoder(strings.NewReader(in)).Decode(v)
+	if err != nil {
+		t.Error("Decode failed, expected no error, got:", err)
+	} else if v.Name != "abc" || v.Age != 12 {
+		t.Error("Decode failed, expected: {abc 12}, got:", *v)
+	}
+}
+
+func TestDecoder_Errors(t *testing.T) {
+	in := `name: "Zoe"
+
+[Server]
+host: "localhost"
+port: 8o
+`
+	_, err := NewDecoder(strings.NewReader(in)).DecodeNode()
+	rjErr, ok := err.(*RJError)
+	if !ok || len(rjErr.Errors) != 1 {
+		t.Fatal("DecodeNode failed, expected one error, got:", err)
+	}
+
+	expected := Position{Offset: 46, Line: 5, Column: 7}
+	if rjErr.Errors[0].Position != expected {
+		t.Error("DecodeNode failed, expected error at:", expected, ", got:", rjErr.Errors[0].Position)
+	}
+
+	readErr := errors.New("read failed")
+	_, err = NewDecoder(io.MultiReader(strings.NewReader(in), errReader{readErr})).DecodeNode()
+	if err != readErr {
+		t.Error("DecodeNode failed, expected:", readErr, ", got:", err)
+	}
+}
+
+func TestBlockSplitter(t *testing.T) {
+	lines := []string{
+		"name: \"Zoe\"\n",  // 0: a pair
+		"arr: [1,\n",       // 1: an array continues
+		"2]\n",             // 2
+		"[Server]\n",       // 3: a section
+		"\n",               // 4: blank lines after the header
+		"host: \"a\"\n",    // 5
+		"# comment\n",      // 6: ends the section
+		"raw: `a\n",        // 7: a raw string continues
+		"\n",               // 8
+		"b`\n",             // 9
+		"text: \"\"\"\n",   // 10: a multi-line string continues
+		"\"\n",             // 11
+		"\n",               // 12
+		"\\\"\"\"\"\"\"\n", // 13
+	}
+	expected := []int{0, 2, 6, 9, 13}
+
+	var b blockSplitter
+	var ends []int
+	for i, l := range lines {
+		if b.line([]byte(l)) {
+			ends = append(ends, i)
+		}
+	}
+
+	if !arrayEquals(ends, expected) || len(ends) != len(expected) {
+		t.Error("Split blocks failed, expected block ends:", expected, ", got:", ends)
+	}
+}
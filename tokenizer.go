@@ -0,0 +1,332 @@
+package rj
+
+import (
+	"io"
+	"strings"
+)
+
+// TokenKind is the kind of a Token.
+type TokenKind int
+
+const (
+	TokenSection     TokenKind = iota + 1 // a section header, like [Server]
+	TokenListItem                         // the '-' that starts a node of a node list
+	TokenKey                              // the name of a pair
+	TokenValue                            // a string, number, bool, time or null value
+	TokenArrayStart                       // '['
+	TokenArrayEnd                         // ']'
+	TokenObjectStart                      // '{'
+	TokenObjectEnd                        // '}'
+	TokenComment                          // a comment, starting with '#' or '//'
+	TokenSectionEnd                       // the blank line or comment that ends a section, or the end of the input
+	TokenDirective                        // a directive, like @include, followed by its argument
+	TokenAnchor                           // an anchor, like &name, followed by the anchored value
+	TokenAlias                            // an alias of an anchored value, like *name
+)
+
+var tokenKindNames = map[TokenKind]string{
+	TokenSection:     "section",
+	TokenListItem:    "list item",
+	TokenKey:         "key",
+	TokenValue:       "value",
+	TokenArrayStart:  "array start",
+	TokenArrayEnd:    "array end",
+	TokenObjectStart: "object start",
+	TokenObjectEnd:   "object end",
+	TokenComment:     "comment",
+	TokenSectionEnd:  "section end",
+	TokenDirective:   "directive",
+	TokenAnchor:      "anchor",
+	TokenAlias:       "alias",
+}
+
+func (k TokenKind) String() string {
+	return tokenKindNames[k]
+}
+
+// Token is a lexical token of an RJ document.
+type Token struct {
+	Kind  TokenKind
+	Pos   Position    // position of the first byte of the token
+	Raw   string      // source text of the token
+	Value interface{} // name of a section, key, directive, anchor or alias, or the decoded value of a TokenValue
+	Base  string      // the base section of a section which inherits from it, like base in [prod : base]
+}
+
+// End returns the byte offset right after the token.
+func (t Token) End() int {
+	return t.Pos.Offset + len(t.Raw)
+}
+
+// container is an array or object the tokenizer is in.
+type container struct {
+	kind    TokenKind // TokenArrayStart or TokenObjectStart
+	start   int
+	items   int
+	needSep bool // an array item has been read, so ',' or ']' must follow
+}
+
+// A Tokenizer splits an RJ document into tokens,
+// following the same rules as Parse.
+type Tokenizer struct {
+	s           *scanner
+	stack       []*container
+	expectValue bool
+	lineContent bool // whether the current line has a key, value or header
+	inSection   bool
+	sectionBody bool // whether the current section has any content yet
+	list        bool // whether the current section is a node list
+	err         error
+}
+
+// NewTokenizer returns a tokenizer of data.
+func NewTokenizer(data []byte) *Tokenizer {
+	s := newScanner(data)
+	s.opts.DisableInterpolation = true // a token has the string as it is written
+	return &Tokenizer{s: s}
+}
+
+// Next returns the next token.
+// It returns io.EOF at the end of the input, or a *ParseError if the input is invalid.
+// The tokenizer stops at the first error, further calls return the same error.
+func (t *Tokenizer) Next() (tok Token, err error) {
+	if t.err != nil {
+		return Token{}, t.err
+	}
+
+	tok, err = t.next()
+	if err != nil {
+		t.err = err
+	}
+	return
+}
+
+func (t *Tokenizer) next() (Token, error) {
+	s := t.s
+	for {
+		if t.expectValue {
+			t.expectValue = false
+			s.skipSpace()
+			return t.value()
+		}
+
+		if len(t.stack) > 0 {
+			return t.nextInContainer()
+		}
+
+		s.skipSpace()
+		if s.offset >= s.len {
+			if t.inSection {
+				t.inSection = false
+				return t.token(TokenSectionEnd, s.offset, nil), nil
+			}
+			return Token{}, io.EOF
+		}
+
+		c := s.data[s.offset]
+		if isLineEnd(c) {
+			blank := !t.lineContent
+			t.lineContent = false
+			if blank && t.inSection && t.sectionBody {
+				t.inSection = false
+				tok := t.token(TokenSectionEnd, s.offset, nil)
+				s.skipLineEnd()
+				return tok, nil
+			}
+			s.skipLineEnd()
+			continue
+		}
+
+		if s.isComment() {
+			if !t.lineContent && t.inSection && t.sectionBody {
+				t.inSection = false
+				return t.token(TokenSectionEnd, s.offset, nil), nil
+			}
+			return t.comment(), nil
+		}
+
+		if t.lineContent {
+			// only a comment may follow a value on the same line
+			return Token{}, s.errorAt(ErrInvalidValue, s.offset)
+		}
+
+		start := s.offset
+		if !t.inSection && c == '[' {
+			name, base, err := s.scanNodeName()
+			if err != nil {
+				return Token{}, err
+			}
+			t.inSection, t.sectionBody, t.list, t.lineContent = true, false, false, true
+			tok := t.token(TokenSection, start, name)
+			tok.Base = base
+			return tok, nil
+		}
+
+		if t.inSection && c == '-' && (t.list || !t.sectionBody) {
+			s.offset++
+			t.sectionBody, t.list = true, true
+			tok := t.token(TokenListItem, start, nil)
+			// a pair may follow the dash on its line, or on the next lines
+			s.skipSpace()
+			t.lineContent = s.offset >= s.len || isLineEnd(s.data[s.offset]) || s.isComment()
+			return tok, nil
+		}
+
+		if t.inSection {
+			t.sectionBody = true
+		}
+		t.lineContent = true
+		if c == '@' {
+			return t.directive()
+		}
+		return t.key()
+	}
+}
+
+func (t *Tokenizer) nextInContainer() (Token, error) {
+	s := t.s
+	top := t.stack[len(t.stack)-1]
+	for {
+		for s.offset < s.len && (isSpace(s.data[s.offset]) || isLineEnd(s.data[s.offset])) {
+			s.offset++
+		}
+		if s.offset >= s.len {
+			return Token{}, s.errorAt(ErrUnexpectedEOF, top.start)
+		}
+		if s.isComment() {
+			return t.comment(), nil
+		}
+
+		start := s.offset
+		c := s.data[s.offset]
+		if top.kind == TokenObjectStart {
+			if c == '}' {
+				s.offset++
+				return t.end(TokenObjectEnd, start), nil
+			}
+			return t.key()
+		}
+
+		switch {
+		case c == ']' && (top.needSep || top.items == 0):
+			s.offset++
+			return t.end(TokenArrayEnd, start), nil
+		case c == ',' && top.needSep:
+			s.offset++
+			top.needSep = false
+		case top.needSep:
+			return Token{}, s.errorAt(ErrInvalidArray, start)
+		default:
+			return t.value()
+		}
+	}
+}
+
+func (t *Tokenizer) key() (Token, error) {
+	s := t.s
+	start := s.offset
+	name := s.scanName()
+	if name == "" {
+		return Token{}, s.errorAt(ErrInvalidName, start)
+	}
+
+	t.expectValue = true
+	tok := t.token(TokenKey, start, name)
+	tok.Raw = name
+	return tok, nil
+}
+
+// directive scans a directive, whose argument is a string.
+func (t *Tokenizer) directive() (Token, error) {
+	s := t.s
+	start := s.offset
+	s.offset++
+	s.skipUntil(func(c byte) bool { return isSpace(c) || isLineEnd(c) })
+	if string(s.data[start+1:s.offset]) != includeDirective {
+		return Token{}, s.errorAt(ErrInvalidDirective, start)
+	}
+	tok := t.token(TokenDirective, start, includeDirective)
+
+	s.skipSpace()
+	if s.offset >= s.len || (s.data[s.offset] != '"' && s.data[s.offset] != '`') {
+		return Token{}, s.errorAt(ErrInvalidString, s.offset)
+	}
+	t.expectValue = true
+	return tok, nil
+}
+
+func (t *Tokenizer) value() (Token, error) {
+	s := t.s
+	start := s.offset
+	if s.offset >= s.len {
+		return Token{}, s.errorAt(ErrUnexpectedEOF, start)
+	}
+
+	switch s.data[s.offset] {
+	case '[':
+		s.offset++
+		t.stack = append(t.stack, &container{kind: TokenArrayStart, start: start})
+		return t.token(TokenArrayStart, start, nil), nil
+	case '{':
+		s.offset++
+		t.stack = append(t.stack, &container{kind: TokenObjectStart, start: start})
+		return t.token(TokenObjectStart, start, nil), nil
+	case '&':
+		s.offset++
+		name := s.scanAnchorName()
+		if name == "" || s.offset >= s.len || !isSpace(s.data[s.offset]) {
+			return Token{}, s.errorAt(ErrInvalidAnchor, start)
+		}
+		t.expectValue = true
+		return t.token(TokenAnchor, start, name), nil
+	case '*':
+		s.offset++
+		name := s.scanAnchorName()
+		if name == "" || !s.isValueEnd() {
+			return Token{}, s.errorAt(ErrInvalidAnchor, start)
+		}
+		t.itemDone()
+		return t.token(TokenAlias, start, name), nil
+	}
+
+	val, err := s.scanScalar()
+	if err != nil {
+		if pe, ok := err.(*ParseError); ok && pe.Line == 0 {
+			pe.Position = s.position(start)
+		}
+		return Token{}, err
+	}
+
+	t.itemDone()
+	tok := t.token(TokenValue, start, val)
+	tok.Raw = strings.TrimRight(tok.Raw, " \t")
+	return tok, nil
+}
+
+// end closes the innermost array or object.
+func (t *Tokenizer) end(kind TokenKind, start int) Token {
+	t.stack = t.stack[:len(t.stack)-1]
+	t.itemDone()
+	return t.token(kind, start, nil)
+}
+
+// itemDone marks a value as complete in the enclosing array.
+func (t *Tokenizer) itemDone() {
+	if l := len(t.stack); l > 0 && t.stack[l-1].kind == TokenArrayStart {
+		t.stack[l-1].items++
+		t.stack[l-1].needSep = true
+	}
+}
+
+func (t *Tokenizer) comment() Token {
+	s := t.s
+	start := s.offset
+	s.skipUntil(isLineEnd)
+	return t.token(TokenComment, start, nil)
+}
+
+// token creates a token from start to the current offset.
+func (t *Tokenizer) token(kind TokenKind, start int, val interface{}) Token {
+	s := t.s
+	return Token{Kind: kind, Pos: s.position(start), Raw: string(s.data[start:s.offset]), Value: val}
+}
@@ -0,0 +1,186 @@
+package rj
+
+import (
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestTokenizer(t *testing.T) {
+	in := `# comment
+name: "Zoe" // comment
+scores: [1, [2]]
+child: {age: 12}
+
+[Servers]
+- host: "a"
+  port: 80
+
+[Empty]
+`
+	expected := []Token{
+		{Kind: TokenComment, Pos: Position{0, 1, 1}, Raw: "# comment"},
+		{Kind: TokenKey, Pos: Position{10, 2, 1}, Raw: "name", Value: "name"},
+		{Kind: TokenValue, Pos: Position{16, 2, 7}, Raw: `"Zoe"`, Value: "Zoe"},
+		{Kind: TokenComment, Pos: Position{22, 2, 13}, Raw: "// comment"},
+		{Kind: TokenKey, Pos: Position{33, 3, 1}, Raw: "scores", Value: "scores"},
+		{Kind: TokenArrayStart, Pos: Position{41, 3, 9}, Raw: "["},
+		{Kind: TokenValue, Pos: Position{42, 3, 10}, Raw: "1", Value: 1},
+		{Kind: TokenArrayStart, Pos: Position{45, 3, 13}, Raw: "["},
+		{Kind: TokenValue, Pos: Position{46, 3, 14}, Raw: "2", Value: 2},
+		{Kind: TokenArrayEnd, Pos: Position{47, 3, 15}, Raw: "]"},
+		{Kind: TokenArrayEnd, Pos: Position{48, 3, 16}, Raw: "]"},
+		{Kind: TokenKey, Pos: Position{50, 4, 1}, Raw: "child", Value: "child"},
+		{Kind: TokenObjectStart, Pos: Position{57, 4, 8}, Raw: "{"},
+		{Kind: TokenKey, Pos: Position{58, 4, 9}, Raw: "age", Value: "age"},
+		{Kind: TokenValue, Pos: Position{63, 4, 14}, Raw: "12", Value: 12},
+		{Kind: TokenObjectEnd, Pos: Position{65, 4, 16}, Raw: "}"},
+		{Kind: TokenSection, Pos: Position{68, 6, 1}, Raw: "[Servers]", Value: "Servers"},
+		{Kind: TokenListItem, Pos: Position{78, 7, 1}, Raw: "-"},
+		{Kind: TokenKey, Pos: Position{80, 7, 3}, Raw: "host", Value: "host"},
+		{Kind: TokenValue, Pos: Position{86, 7, 9}, Raw: `"a"`, Value: "a"},
+		{Kind: TokenKey, Pos: Position{92, 8, 3}, Raw: "port", Value: "port"},
+		{Kind: TokenValue, Pos: Position{98, 8, 9}, Raw: "80", Value: 80},
+		{Kind: TokenSectionEnd, Pos: Position{101, 9, 1}},
+		{Kind: TokenSection, Pos: Position{102, 10, 1}, Raw: "[Empty]", Value: "Empty"},
+		{Kind: TokenSectionEnd, Pos: Position{110, 11, 1}},
+	}
+
+	tz := NewTokenizer([]byte(in))
+	for i := 0; ; i++ {
+		tok, err := tz.Next()
+		if err == io.EOF {
+			if i != len(expected) {
+				t.Error("Tokenizer failed, expected", len(expected), "tokens, got:", i)
+			}
+			break
+		}
+		if err != nil {
+			t.Fatal("Tokenizer failed, expected no error, got:", err)
+		}
+		if i >= len(expected) {
+			t.Fatal("Tokenizer failed, unexpected token:", tok)
+		}
+		if tok != expected[i] {
+			t.Errorf("Tokenizer failed, expected token %d: %+v, got: %+v", i, expected[i], tok)
+		}
+	}
+}
+
+func TestTokenizerErrors(t *testing.T) {
+	cases := []struct {
+		input string
+		code  ErrorCode
+	}{
+		{input: `name: "a\c"`, code: ErrInvalidEscape},
+		{input: `arr: ["a" "b"]`, code: ErrInvalidArray},
+		{input: `arr: [1, 2`, code: ErrUnexpectedEOF},
+		{input: `name: "a" b`, code: ErrInvalidValue},
+		{input: `[Bad Name]`, code: ErrInvalidNodeName},
+		{input: `a: &b[1]`, code: ErrInvalidAnchor},
+		{input: `a: *b.c`, code: ErrInvalidAnchor},
+	}
+
+	for _, tc := range cases {
+		tz := NewTokenizer([]byte(tc.input))
+		var err error
+		for err == nil {
+			_, err = tz.Next()
+		}
+		if !errors.Is(err, tc.code) {
+			t.Error("Tokenizer failed, input:", tc.input, ", expected:", tc.code, ", got:", err)
+		}
+
+		_, again := tz.Next()
+		if again != err {
+			t.Error("Tokenizer failed, expected the same error again, got:", again)
+		}
+	}
+}
+
+func TestTokenizerMultilineString(t *testing.T) {
+	in := "[S]\ntext: \"\"\"\n\n  a\n  \"\"\"\nnext: 1\n"
+	expected := []TokenKind{TokenSection, TokenKey, TokenValue, TokenKey, TokenValue, TokenSectionEnd}
+
+	tz := NewTokenizer([]byte(in))
+	for i, kind := range expected {
+		tok, err := tz.Next()
+		if err != nil || tok.Kind != kind {
+			t.Fatal("Tokenize multi-line string failed, token", i, ", expected:", kind, ", got:", tok.Kind, err)
+		}
+		if i == 2 && (tok.Value != "\na\n" || tok.Raw != "\"\"\"\n\n  a\n  \"\"\"") {
+			t.Errorf("Tokenize multi-line string failed, got value: %q, raw: %q", tok.Value, tok.Raw)
+		}
+	}
+}
+
+func TestTokenizerAnchors(t *testing.T) {
+	in := "a: &x [1, *y]\nb: *x\n"
+	expected := []TokenKind{TokenKey, TokenAnchor, TokenArrayStart, TokenValue, TokenAlias, TokenArrayEnd, TokenKey, TokenAlias}
+
+	tz := NewTokenizer([]byte(in))
+	for i, kind := range expected {
+		tok, err := tz.Next()
+		if err != nil || tok.Kind != kind {
+			t.Fatal("Tokenize anchors failed, token", i, ", expected:", kind, ", got:", tok.Kind, err)
+		}
+		if (i == 1 && tok.Value != "x") || (i == 4 && tok.Value != "y") {
+			t.Error("Tokenize anchors failed, token", i, ", got:", tok.Value)
+		}
+	}
+
+	doc, err := ParseDocument([]byte(in))
+	if err != nil || doc.String() != in {
+		t.Error("ParseDocument with anchors failed, got:", err)
+	}
+}
+
+func TestTokenizeReference(t *testing.T) {
+	tz := NewTokenizer([]byte(`url: "${host}/a"`))
+	tz.Next()
+	if tok, err := tz.Next(); err != nil || tok.Value != "${host}/a" {
+		t.Error("Tokenizer failed, expected the string as it is written, got:", tok, err)
+	}
+}
+
+func TestTokenizeInheritance(t *testing.T) {
+	tz := NewTokenizer([]byte("[prod : base]\nhost: \"p\"\n"))
+	tok, err := tz.Next()
+	expected := Token{Kind: TokenSection, Pos: Position{0, 1, 1}, Raw: "[prod : base]", Value: "prod", Base: "base"}
+	if err != nil || tok != expected {
+		t.Errorf("Tokenizer failed, expected: %+v, got: %+v, %v", expected, tok, err)
+	}
+
+	doc, err := ParseDocument([]byte("[prod : base]\nhost: \"p\"\n"))
+	if err != nil || doc.Root().Children[0].Name() != "prod" || doc.Root().Children[0].Base() != "base" {
+		t.Error("ParseDocument failed, expected section prod with base base, got:", doc.Root().Children, err)
+	}
+}
+
+func TestTokenizeLoneDash(t *testing.T) {
+	in := "[Servers]\n-\n  host: \"a\"\n-  # b\n  host: \"b\"\n"
+	expected := []TokenKind{TokenSection, TokenListItem, TokenKey, TokenValue, TokenListItem, TokenComment,
+		TokenKey, TokenValue, TokenSectionEnd}
+
+	tz := NewTokenizer([]byte(in))
+	var kinds []TokenKind
+	for {
+		tok, err := tz.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal("Tokenizer failed, expected no error, got:", err)
+		}
+		kinds = append(kinds, tok.Kind)
+	}
+	if len(kinds) != len(expected) {
+		t.Fatal("Tokenizer failed, expected:", expected, ", got:", kinds)
+	}
+	for i := range kinds {
+		if kinds[i] != expected[i] {
+			t.Error("Tokenizer failed, expected:", expected, ", got:", kinds)
+			break
+		}
+	}
+}
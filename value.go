@@ -0,0 +1,194 @@
+package rj
+
+import (
+	"math"
+	"time"
+)
+
+// Kind is the kind of an RJ value.
+type Kind int
+
+const (
+	KindInvalid  Kind = iota // no value, the kind of a missing key
+	KindString               // a string
+	KindInt                  // an int, or an int64 or uint64 which is out of the range of int
+	KindFloat                // a float64
+	KindBool                 // a bool
+	KindTime                 // a time.Time
+	KindDuration             // a time.Duration
+	KindByteSize             // a ByteSize
+	KindNull                 // null
+	KindArray                // an array, like []int or []interface{}
+	KindNode                 // a *Node, from a section or an object
+	KindNodeList             // a []*Node, from a node list
+)
+
+var kindNames = map[Kind]string{
+	KindInvalid:  "invalid",
+	KindString:   "string",
+	KindInt:      "int",
+	KindFloat:    "float",
+	KindBool:     "bool",
+	KindTime:     "time",
+	KindDuration: "duration",
+	KindByteSize: "byte size",
+	KindNull:     "null",
+	KindArray:    "array",
+	KindNode:     "node",
+	KindNodeList: "node list",
+}
+
+func (k Kind) String() string {
+	return kindNames[k]
+}
+
+// kindOf returns the kind of a value of a node.
+func kindOf(v interface{}) Kind {
+	switch v.(type) {
+	case nil:
+		return KindNull
+	case string:
+		return KindString
+	case int, int64, uint64:
+		return KindInt
+	case float64:
+		return KindFloat
+	case bool:
+		return KindBool
+	case time.Time:
+		return KindTime
+	case time.Duration:
+		return KindDuration
+	case ByteSize:
+		return KindByteSize
+	case *Node:
+		return KindNode
+	case []*Node:
+		return KindNodeList
+	case []interface{}, []string, []int, []int64, []uint64, []float64, []bool, []time.Time, []time.Duration, []ByteSize:
+		return KindArray
+	}
+	return KindInvalid
+}
+
+// Value is a value of a node, as given to the function of Node.Range.
+// Its getters return the zero value if the value is of another kind, like the getters of Node.
+type Value struct {
+	v interface{}
+}
+
+// Kind returns the kind of the value.
+func (v Value) Kind() Kind {
+	return kindOf(v.v)
+}
+
+// Interface returns the value itself, as Node.Get does.
+func (v Value) Interface() interface{} {
+	return v.v
+}
+
+// IsNull reports whether the value is null.
+func (v Value) IsNull() bool {
+	return v.v == nil
+}
+
+// String returns a string value, or the RJ text of a value of another kind.
+func (v Value) String() string {
+	if s, ok := v.v.(string); ok {
+		return s
+	}
+	text, _ := marshalValue(v.v)
+	return string(text)
+}
+
+// Int returns an int value, or an int64 or uint64 value which is in the range of int.
+// Use Int64 or Uint64 for the values which may be out of that range.
+func (v Value) Int() int {
+	switch i := v.v.(type) {
+	case int:
+		return i
+	case int64:
+		if n := int(i); int64(n) == i {
+			return n
+		}
+	case uint64:
+		if n := int(i); n >= 0 && uint64(n) == i {
+			return n
+		}
+	}
+	return 0
+}
+
+// Int64 returns an integer value which is in the range of int64.
+func (v Value) Int64() int64 {
+	switch i := v.v.(type) {
+	case int:
+		return int64(i)
+	case int64:
+		return i
+	case uint64:
+		if i <= math.MaxInt64 {
+			return int64(i)
+		}
+	}
+	return 0
+}
+
+// Uint64 returns an integer value which is not negative.
+func (v Value) Uint64() uint64 {
+	switch i := v.v.(type) {
+	case int:
+		if i >= 0 {
+			return uint64(i)
+		}
+	case int64:
+		if i >= 0 {
+			return uint64(i)
+		}
+	case uint64:
+		return i
+	}
+	return 0
+}
+
+// Float returns a float value.
+func (v Value) Float() float64 {
+	f, _ := v.v.(float64)
+	return f
+}
+
+// Bool returns a bool value.
+func (v Value) Bool() bool {
+	b, _ := v.v.(bool)
+	return b
+}
+
+// Time returns a time value.
+func (v Value) Time() time.Time {
+	t, _ := v.v.(time.Time)
+	return t
+}
+
+// Duration returns a duration value.
+func (v Value) Duration() time.Duration {
+	d, _ := v.v.(time.Duration)
+	return d
+}
+
+// ByteSize returns a byte size value.
+func (v Value) ByteSize() ByteSize {
+	b, _ := v.v.(ByteSize)
+	return b
+}
+
+// Node returns a node value, or nil.
+func (v Value) Node() *Node {
+	n, _ := v.v.(*Node)
+	return n
+}
+
+// NodeList returns a node list value, or nil.
+func (v Value) NodeList() []*Node {
+	list, _ := v.v.([]*Node)
+	return list
+}
@@ -0,0 +1,91 @@
+package rj
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestNode_Kind(t *testing.T) {
+	in := `s: "a"
+i: 1
+big: 18446744073709551615
+f: 1.5
+b: true
+t: 2019-10-11
+d: 30s
+size: 1KB
+n: null
+arr: [1, "a"]
+obj: {x: 1}
+
+[List]
+- x: 1
+`
+	node, err := ParseString(in)
+	if err != nil {
+		t.Fatal("ParseString failed, expected no error, got:", err)
+	}
+
+	cases := map[string]Kind{
+		"s":       KindString,
+		"i":       KindInt,
+		"big":     KindInt,
+		"f":       KindFloat,
+		"b":       KindBool,
+		"t":       KindTime,
+		"d":       KindDuration,
+		"size":    KindByteSize,
+		"n":       KindNull,
+		"arr":     KindArray,
+		"obj":     KindNode,
+		"obj.x":   KindInt,
+		"List":    KindNodeList,
+		"missing": KindInvalid,
+		"s.x":     KindInvalid,
+	}
+	for path, expected := range cases {
+		if k := node.Kind(path); k != expected {
+			t.Error("Kind failed, path:", path, ", expected:", expected, ", got:", k)
+		}
+	}
+}
+
+func TestValue(t *testing.T) {
+	type testCase struct {
+		v    Value
+		kind Kind
+		text string
+	}
+
+	node := NewNode()
+	node.Set("x", 1)
+	cases := []testCase{
+		{Value{"a\"b"}, KindString, "a\"b"},
+		{Value{8080}, KindInt, "8080"},
+		{Value{1.5}, KindFloat, "1.5"},
+		{Value{nil}, KindNull, "null"},
+		{Value{time.Minute}, KindDuration, "1m0s"},
+		{Value{[]int{1, 2}}, KindArray, "[1,2]"},
+		{Value{node}, KindNode, "{\n\tx: 1\n}"},
+	}
+	for _, c := range cases {
+		if c.v.Kind() != c.kind || c.v.String() != c.text {
+			t.Error("Value failed, expected:", c.kind, c.text, ", got:", c.v.Kind(), c.v.String())
+		}
+	}
+
+	v := Value{8080}
+	if v.Int() != 8080 || v.Float() != 0 || v.Node() != nil || v.IsNull() || v.Interface() != 8080 {
+		t.Error("Value failed, expected an int value, got:", v.Interface())
+	}
+	if v = (Value{uint64(math.MaxUint64)}); v.Int() != 0 || v.Int64() != 0 || v.Uint64() != math.MaxUint64 {
+		t.Error("Value failed, expected an uint64 value out of the range of int, got:", v.Int(), v.Int64(), v.Uint64())
+	}
+	if v = (Value{int64(-5)}); v.Int() != -5 || v.Int64() != -5 || v.Uint64() != 0 {
+		t.Error("Value failed, expected an int64 value in the range of int, got:", v.Int(), v.Int64(), v.Uint64())
+	}
+	if (Value{node}).Node() != node || (Value{time.Minute}).Duration() != time.Minute {
+		t.Error("Value failed, expected the value of its kind")
+	}
+}